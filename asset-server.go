@@ -1,29 +1,40 @@
 package vitgo
 
 import (
-	"embed"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
 	"io/fs"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
-//go:embed react
-var embedFiles embed.FS
-
 // FileServer is a customized version of http.FileServer
-// that can handle either an embed.FS or a os.DirFS fs.FS.
+// that can handle an embed.FS, an os.DirFS or any other fs.FS.
 // Since development directories used for hot updates
 // can contain dot files (potentially with sensitive
 // information) the code checks to make sure that dot files
 // are not served.
 func (vg *VitGo) FileServer() (http.Handler, error) {
-	// First, make sure if our fs.FS is from an embed.FS,
-	// that we adjust where the FS is "pointing".
-	target, err := correctEmbedFS(vg.DistFS, vg.JSProjectPath)
-	if err != nil {
-		return nil, err
+	// First, make sure that we adjust where the FS is "pointing".
+	// NewVitGo already did, and its DistFS may deliberately be
+	// FS itself (see ViteConfig.AssetsPath).
+	target := vg.DistFS
+	if vg.config == nil {
+		var err error
+
+		target, err = correctFS(vg.DistFS, "", vg.JSProjectPath)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Prevent directory listings
@@ -33,6 +44,12 @@ func (vg *VitGo) FileServer() (http.Handler, error) {
 
 	handler := vg.guardedFileServer(wrapped)
 
+	if base := vg.localBasePath(); base != "" && vg.Environment == "production" {
+		handler = http.StripPrefix(base, handler)
+	}
+
+	handler = vg.canonicalHost(handler)
+
 	return handler, nil
 }
 
@@ -50,82 +67,392 @@ func (vg *VitGo) FileServer() (http.Handler, error) {
 func (vg *VitGo) guardedFileServer(serveDir fs.FS) http.Handler {
 	stripPrefix := "/"
 
+	// The file servers are built once, not per request. In
+	// production we actually want to read from the dist subdir
+	// of the JSDir.
+	distDir, distErr := fs.Sub(serveDir, vg.AssetPath)
+	distServer := vg.distFileServer(distDir)
+	devServer := vg.devFileServer(serveDir, stripPrefix)
+
 	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" {
+			// as left by StripPrefix for the bare BasePath
+			r.URL.Path = "/"
+		}
+
 		prefixLen := len(stripPrefix)
 		rest := r.URL.Path[prefixLen:]
 		parts := strings.Split(rest, "/")
 
 		// Now walk the parts and make sure none of them are
-		// either "hidden" files or directories.
-		for _, stem := range parts {
-			if len(stem) > 0 && stem[:1] == "." {
-				http.NotFound(w, r)
-				return
+		// either "hidden" files or directories. The path is
+		// checked again once fully decoded, with backslashes as
+		// separators, so encoded or Windows-style traversal can
+		// not slip through. This has to happen before any
+		// cleaning, which would fold .. segments away.
+		decoded := normalizedPath(r.URL.Path)
+		normalized := strings.Split(decoded, "/")
+
+		for _, stems := range [][]string{parts, normalized} {
+			for _, stem := range stems {
+				if len(stem) > 0 && stem[:1] == "." {
+					http.NotFound(w, r)
+					return
+				}
 			}
 		}
 
+		// Source maps exist in the build but, unless wanted,
+		// are kept from the public.
+		if !vg.ServeSourceMaps && strings.HasSuffix(strings.ToLower(decoded), ".map") {
+			http.NotFound(w, r)
+			return
+		}
+
+		if vg.applyCORS(w, r) {
+			return
+		}
+
+		if !allowMethod(w, r) {
+			return
+		}
+
+		if vg.Debug && r.URL.Path == CONFIG_DUMP_PATH {
+			vg.serveConfigDump(w, r)
+			return
+		}
+
+		if rest == "" && vg.serveRoot(w, r) {
+			return
+		}
+
+		// The manifest leaks the internal file mapping, so it is
+		// only served when explicitly made public.
+		if !vg.ManifestPublic && vg.isManifestPath(rest) {
+			http.NotFound(w, r)
+			return
+		}
+
 		// handle any special-cased files
 		if len(parts) > 0 {
 			baseFile := parts[len(parts)-1]
 
 			if baseFile == "preamble.js" {
 				// react preamble file
-				bytes, err := embedFiles.ReadFile("react/preamble.js")
+				bytes, err := vg.preamble()
 				if err != nil {
-					log.Println("could not load preamble:", err)
+					vg.logger().Error("could not load preamble", "error", err)
 					http.NotFound(w, r)
 
 					return
 				}
 
-				serveOneFile(w, r, bytes, "application/javascript")
+				vg.serveOneFile(w, r, bytes, "application/javascript")
 				return
 			}
 		}
 
-		if vg.Debug {
+		// Skip the dir listing entirely when nothing would be logged.
+		if vg.Debug && !vg.DisableLogging {
 			escapedURLPath := strings.Replace(r.URL.Path, "\n", "", -1)
 			escapedURLPath = strings.Replace(escapedURLPath, "\r", "", -1)
 
-			log.Println("entered FS", escapedURLPath)
+			vg.logger().Info("entered FS", "path", escapedURLPath)
 			dir, err := fs.ReadDir(serveDir, ".")
 
 			if err != nil {
-				log.Println("could not read the asset dir", err)
+				vg.logger().Error("could not read the asset dir", "error", err)
 				http.NotFound(w, r)
 				return
 			}
 
 			for _, item := range dir {
-				log.Println(item.Name())
+				vg.logger().Info("asset dir entry", "name", item.Name())
 			}
 		}
 
-		var loggingFS http.Handler
-		var fileServer http.Handler
+		vg.setIsolationHeaders(w, r.URL.Path)
 
 		if vg.Environment == "production" {
-			// We actually want to read from the dist subdir of
-			// the JSDir.
-			newDir, err := fs.Sub(serveDir, vg.AssetPath)
-			if err != nil {
+			if distErr != nil {
 				w.WriteHeader(http.StatusNotFound)
 				return
 			}
 
-			loggingFS = logRequest(http.FileServer(http.FS(newDir)))
-			fileServer = loggingFS
-		} else {
-			loggingFS = logRequest(http.FileServer(http.FS(serveDir)))
-			fileServer = http.StripPrefix(stripPrefix, loggingFS)
+			if vg.normalizeCase(w, r, rest) {
+				return
+			}
+
+			rest = r.URL.Path[prefixLen:]
+
+			// Asset dirs never have an index.html, so directory
+			// requests under them get a 404 straight away.
+			if vg.isAssetDirPath(rest) {
+				http.NotFound(w, r)
+				return
+			}
+
+			// With a CDN BasePath, assets are not served here.
+			if isAbsoluteURL(vg.BasePath) && vg.isAssetPath(rest) {
+				http.NotFound(w, r)
+				return
+			}
+
+			if vg.SPAFallback && vg.serveSPAFallback(w, r, distDir, rest) {
+				return
+			}
+
+			if vg.VerifyOnServe {
+				if err := vg.verifyFile(distDir, path.Clean(rest)); err != nil {
+					vg.logger().Error("could not verify file", "error", err)
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+			}
+
+			distServer.ServeHTTP(w, r)
+			return
 		}
 
-		fileServer.ServeHTTP(w, r)
+		devServer.ServeHTTP(w, r)
 	}
 
 	return http.HandlerFunc(handler)
 }
 
+// distFileServer serves the production build in distDir, with
+// its caching, encoding and 404 handling, and logs requests.
+func (vg *VitGo) distFileServer(distDir fs.FS) http.Handler {
+	if distDir == nil {
+		return http.NotFoundHandler()
+	}
+
+	plainFS := http.FileServer(http.FS(distDir))
+
+	return vg.logRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+
+		if vg.Custom404 && vg.serveCustom404(w, r, distDir, name) {
+			return
+		}
+
+		vg.setCacheHeaders(w, name)
+		vg.setETag(w, distDir, name)
+
+		if ctype := vg.contentType(name); ctype != "" {
+			w.Header().Set("Content-Type", ctype)
+		}
+
+		if vg.servePrecompressed(w, r, distDir, name) {
+			return
+		}
+
+		w = vg.gzipOnTheFly(w, r, distDir, name)
+		if gw, ok := w.(*gzipWriter); ok {
+			defer gw.Close()
+		}
+
+		plainFS.ServeHTTP(w, r)
+	}))
+}
+
+// devFileServer serves the JS project in serveDir, as is, and
+// logs requests.
+func (vg *VitGo) devFileServer(serveDir fs.FS, stripPrefix string) http.Handler {
+	plainFS := http.FileServer(http.FS(serveDir))
+
+	loggingFS := vg.logRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vg.setDevCacheHeaders(w, r.URL.Path)
+
+		if ctype := vg.contentType(r.URL.Path); ctype != "" {
+			w.Header().Set("Content-Type", ctype)
+		}
+
+		plainFS.ServeHTTP(w, r)
+	}))
+
+	return http.StripPrefix(stripPrefix, loggingFS)
+}
+
+// allowMethod answers anything but GET and HEAD, which is all a
+// static file server supports, with a 405, and reports whether
+// the request may go on.
+func allowMethod(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+
+	w.Header().Set("Allow", "GET, HEAD")
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+	return false
+}
+
+// normalizedPath undoes any leftover percent-encoding in a
+// request path (as in double-encoded %252e%252e) and turns
+// backslashes into slashes.
+func normalizedPath(urlPath string) string {
+	decoded := urlPath
+
+	for i := 0; i < 3; i++ {
+		unescaped, err := url.PathUnescape(decoded)
+		if err != nil || unescaped == decoded {
+			break
+		}
+
+		decoded = unescaped
+	}
+
+	return strings.ReplaceAll(decoded, "\\", "/")
+}
+
+// validateRootBehavior checks a RootBehavior setting and returns
+// the behavior to use, defaulting to "index".
+func validateRootBehavior(behavior string) (string, error) {
+	switch {
+	case behavior == "":
+		return ROOT_INDEX, nil
+
+	case behavior == ROOT_INDEX || behavior == ROOT_404:
+		return behavior, nil
+
+	case strings.HasPrefix(behavior, ROOT_REDIRECT):
+		target := strings.TrimPrefix(behavior, ROOT_REDIRECT)
+
+		// only local paths; //host would leave the site
+		if strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//") && target != "/" {
+			return behavior, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrBadRootBehavior, behavior)
+}
+
+// serveRoot applies RootBehavior to a request for /, and
+// reports whether the response was written.
+func (vg *VitGo) serveRoot(w http.ResponseWriter, r *http.Request) bool {
+	switch {
+	case vg.RootBehavior == ROOT_404:
+		http.NotFound(w, r)
+		return true
+
+	case strings.HasPrefix(vg.RootBehavior, ROOT_REDIRECT):
+		target := strings.TrimPrefix(vg.RootBehavior, ROOT_REDIRECT)
+		http.Redirect(w, r, target, http.StatusFound)
+		return true
+	}
+
+	return false
+}
+
+// isManifestPath reports whether the request path (without its
+// leading slash) points at the build manifest. The path is
+// cleaned first, as the file server would, so //manifest.json
+// or x/../manifest.json are caught too.
+func (vg *VitGo) isManifestPath(rest string) bool {
+	rest = strings.TrimPrefix(path.Clean("/"+rest), "/")
+
+	manifestFile := vg.manifestFile
+	if manifestFile == "" {
+		manifestFile = "manifest.json"
+	}
+
+	if vg.Environment == "production" {
+		// production serves from inside the assets dir
+		return rest == manifestFile
+	}
+
+	assetPath := vg.AssetPath
+	if assetPath == "" {
+		assetPath = "dist"
+	}
+
+	return rest == path.Join(assetPath, manifestFile)
+}
+
+// serveSPAFallback answers a request for a client-side route,
+// i.e. a missing path with no file extension outside the assets
+// dir, with index.html, and reports whether it did. allowMethod
+// has already limited the request to GET and HEAD.
+func (vg *VitGo) serveSPAFallback(w http.ResponseWriter, r *http.Request, fsys fs.FS, rest string) bool {
+	name := strings.Trim(path.Clean("/"+rest), "/")
+	if name == "" || path.Ext(name) != "" {
+		return false
+	}
+
+	if vg.isAssetPath(name) {
+		return false
+	}
+
+	if _, err := fs.Stat(fsys, name); err == nil {
+		return false
+	}
+
+	index, err := fs.ReadFile(fsys, "index.html")
+	if err != nil {
+		return false
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	vg.serveOneFile(w, r, index, "text/html; charset=utf-8")
+
+	return true
+}
+
+// serveCustom404 answers a request for a missing file with the
+// build's 404.html, and reports whether it did. Without a
+// 404.html the file server's plain 404 is left to happen.
+func (vg *VitGo) serveCustom404(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) bool {
+	if name == "" {
+		name = "."
+	}
+
+	if _, err := fs.Stat(fsys, name); err == nil {
+		return false
+	}
+
+	page, err := fs.ReadFile(fsys, "404.html")
+	if err != nil {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusNotFound)
+
+	if r.Method != http.MethodHead {
+		if _, err := w.Write(page); err != nil {
+			vg.logger().Error("could not write 404 page", "error", err)
+		}
+	}
+
+	return true
+}
+
+// isAssetPath reports whether the request path (without its
+// leading slash) is under the assets URL prefix.
+func (vg *VitGo) isAssetPath(rest string) bool {
+	assetDir := strings.Trim(vg.URLPrefix, "/")
+
+	return assetDir != "" && (rest == assetDir || strings.HasPrefix(rest, assetDir+"/"))
+}
+
+// isAssetDirPath reports whether the request path (without its
+// leading slash) is a directory under the assets URL prefix,
+// e.g. assets/ or assets/images/.
+func (vg *VitGo) isAssetDirPath(rest string) bool {
+	assetDir := strings.Trim(vg.URLPrefix, "/")
+	if assetDir == "" {
+		return false
+	}
+
+	if rest != assetDir && !strings.HasPrefix(rest, assetDir+"/") {
+		return false
+	}
+
+	return rest == assetDir || strings.HasSuffix(rest, "/")
+}
+
 // Wrapper file system to prevent listing of directories
 // forked from: https://www.alexedwards.net/blog/disable-http-fileserver-directory-listings
 type wrapperFS struct {
@@ -139,6 +466,11 @@ func (wrpr wrapperFS) Open(path string) (fs.File, error) {
 		return nil, err
 	}
 
+	if err := checkSymlinkEscape(f, path); err != nil {
+		f.Close()
+		return nil, err
+	}
+
 	s, err := f.Stat()
 	if err != nil {
 		return nil, err
@@ -161,15 +493,62 @@ func (wrpr wrapperFS) Open(path string) (fs.File, error) {
 	return f, nil
 }
 
-// serveOneFile is used for serving special-cased files.
-func serveOneFile(w http.ResponseWriter, r *http.Request, data []byte, ctype string) {
-	w.Header().Add("Content-Type", ctype)
+// checkSymlinkEscape rejects a disk-backed file (from os.DirFS)
+// whose real path, symlinks resolved, is outside the FS root,
+// so a symlink in the served tree can not leak other files.
+//
+// The check is best effort. It only applies when Open returns an
+// *os.File, so embed.FS, which can not hold symlinks, and any
+// wrapping FS that hides the *os.File are not checked. The FS
+// root is derived from the file's Name(), so it is also skipped
+// when Name() does not end in "/"+name, as for an FS that maps
+// names rather than joining them onto a directory. Serving a tree
+// nobody untrusted can write to remains the real protection.
+func checkSymlinkEscape(f fs.File, name string) error {
+	osFile, ok := f.(*os.File)
+	if !ok || name == "." {
+		return nil
+	}
+
+	fullName := filepath.ToSlash(osFile.Name())
+	if !strings.HasSuffix(fullName, "/"+name) {
+		return nil
+	}
 
-	_, err := w.Write(data)
+	root, err := filepath.EvalSymlinks(filepath.FromSlash(strings.TrimSuffix(fullName, "/"+name)))
+	if err != nil {
+		return err
+	}
 
+	resolved, err := filepath.EvalSymlinks(osFile.Name())
 	if err != nil {
-		log.Println("could not write file:", err)
+		return err
 	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return nil
+}
+
+// oneFileModTime is the modtime of special-cased files. They
+// are built into the binary or generated, so they change no more
+// often than the process restarts.
+var oneFileModTime = time.Now()
+
+// serveOneFile is used for serving special-cased files. It goes
+// through http.ServeContent, for ranges, Content-Length and
+// conditional requests.
+func (vg *VitGo) serveOneFile(w http.ResponseWriter, r *http.Request, data []byte, ctype string) {
+	if override := vg.contentType(r.URL.Path); override != "" {
+		ctype = override
+	}
+
+	w.Header().Set("Content-Type", ctype)
+
+	http.ServeContent(w, r, path.Base(r.URL.Path), oneFileModTime, bytes.NewReader(data))
 }
 
 // Logger writes out status codes:
@@ -198,8 +577,38 @@ func (w *WriterWrapper) Write(buf []byte) (int, error) {
 	return w.Writer.Write(buf)
 }
 
-func logRequest(next http.Handler) http.Handler {
+// DEFAULT_REQUEST_ID_HEADER carries the request ID when
+// RequestIDHeader is empty.
+const DEFAULT_REQUEST_ID_HEADER = "X-Request-ID"
+
+// requestID returns the request's ID from the request ID
+// header, generating a random one if it has none, and echoes
+// it on the response.
+func (vg *VitGo) requestID(w http.ResponseWriter, r *http.Request) string {
+	header := vg.RequestIDHeader
+	if header == "" {
+		header = DEFAULT_REQUEST_ID_HEADER
+	}
+
+	id := r.Header.Get(header)
+	if id == "" {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err == nil {
+			id = hex.EncodeToString(buf)
+		}
+	}
+
+	if id != "" {
+		w.Header().Set(header, id)
+	}
+
+	return id
+}
+
+func (vg *VitGo) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := vg.requestID(w, r)
+
 		ww := NewRespWriter(w)
 		next.ServeHTTP(ww, r)
 
@@ -207,11 +616,44 @@ func logRequest(next http.Handler) http.Handler {
 			escapedReqURI := strings.Replace(r.URL.RequestURI(), "\n", "", -1)
 			escapedReqURI = strings.Replace(escapedReqURI, "\r", "", -1)
 
-			log.Printf(
-				"%s - %s %s %s (%d)",
-				r.RemoteAddr, r.Proto, r.Method,
-				escapedReqURI, ww.RetCode,
+			level := slog.LevelInfo
+			if vg.Debug {
+				level = slog.LevelDebug
+			}
+
+			vg.logger().Log(
+				r.Context(), level, "request",
+				"remote_addr", r.RemoteAddr,
+				"proto", r.Proto,
+				"method", r.Method,
+				"path", escapedReqURI,
+				"status", ww.RetCode,
+				"request_id", requestID,
 			)
 		}()
 	})
 }
+
+// discardLogger is used when DisableLogging is set.
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// logger returns the Logger NewVitGo will log through.
+func (vc *ViteConfig) logger() *slog.Logger {
+	vg := VitGo{Logger: vc.Logger, DisableLogging: vc.DisableLogging}
+	return vg.logger()
+}
+
+// logger returns the Logger to log through: slog.Default() if
+// none is set, or one that drops everything if DisableLogging
+// is set.
+func (vg *VitGo) logger() *slog.Logger {
+	if vg.DisableLogging {
+		return discardLogger
+	}
+
+	if vg.Logger == nil {
+		return slog.Default()
+	}
+
+	return vg.Logger
+}
@@ -0,0 +1,261 @@
+package vitgo
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testManifest = `{
+	"src/main.tsx": {
+		"file": "assets/main-4f2a1b.js",
+		"src": "src/main.tsx",
+		"isEntry": true,
+		"css": ["assets/main-9c8d7e.css"]
+	}
+}`
+
+// newProdVitGo returns a production VitGo serving fsys, which
+// holds the build under dist/.
+func newProdVitGo(t testing.TB, fsys fs.FS) *VitGo {
+	t.Helper()
+
+	vg, err := NewVitGo(&ViteConfig{
+		FS:             fsys,
+		FSRoot:         ".",
+		Environment:    "production",
+		Platform:       "react",
+		DisableLogging: true,
+	})
+	if err != nil {
+		t.Fatalf("NewVitGo: %v", err)
+	}
+
+	return vg
+}
+
+// prodFS is a minimal production build.
+func prodFS() fstest.MapFS {
+	return fstest.MapFS{
+		"dist/.vite/manifest.json":    {Data: []byte(testManifest)},
+		"dist/index.html":             {Data: []byte("<!doctype html><title>app</title>")},
+		"dist/assets/main-4f2a1b.js":  {Data: []byte("console.log('main')")},
+		"dist/assets/main-9c8d7e.css": {Data: []byte("body{margin:0}")},
+		"dist/assets/images/logo.svg": {Data: []byte("<svg/>")},
+	}
+}
+
+// fileServer returns vg's FileServer, failing the test on error.
+func fileServer(t testing.TB, vg *VitGo) http.Handler {
+	t.Helper()
+
+	handler, err := vg.FileServer()
+	if err != nil {
+		t.Fatalf("FileServer: %v", err)
+	}
+
+	return handler
+}
+
+// serve runs a request for target through handler.
+func serve(handler http.Handler, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+
+	return rec
+}
+
+func TestFileServerSymlinkEscape(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, file := range prodFS() {
+		full := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, file.Data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Symlink("/etc/passwd", filepath.Join(dir, "dist", "passwd")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	handler := fileServer(t, newProdVitGo(t, os.DirFS(dir)))
+
+	if rec := serve(handler, http.MethodGet, "/passwd"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /passwd = %d, want 404", rec.Code)
+	}
+
+	if rec := serve(handler, http.MethodGet, "/assets/main-4f2a1b.js"); rec.Code != http.StatusOK {
+		t.Errorf("GET /assets/main-4f2a1b.js = %d, want 200", rec.Code)
+	}
+}
+
+func TestFileServerTraversal(t *testing.T) {
+	fsys := prodFS()
+	fsys["secret.txt"] = &fstest.MapFile{Data: []byte("secret")}
+	fsys["dist/.env"] = &fstest.MapFile{Data: []byte("TOKEN=secret")}
+
+	devFS := fstest.MapFS{
+		"secret.txt":            {Data: []byte("secret")},
+		"frontend/package.json": {Data: []byte(`{"devDependencies": {"vite": "^5.0.0"}}`)},
+		"frontend/.env":         {Data: []byte("TOKEN=secret")},
+		"frontend/assets/a.js":  {Data: []byte("")},
+		"frontend/index.html":   {Data: []byte("<!doctype html>")},
+	}
+
+	devVG, err := NewVitGo(&ViteConfig{
+		FS:             devFS,
+		Environment:    "development",
+		DisableLogging: true,
+	})
+	if err != nil {
+		t.Fatalf("NewVitGo: %v", err)
+	}
+
+	handlers := map[string]http.Handler{
+		"production":  fileServer(t, newProdVitGo(t, fsys)),
+		"development": fileServer(t, devVG),
+	}
+
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{"encoded dots", "/%2e%2e/secret.txt"},
+		{"encoded dots upper", "/%2E%2E/secret.txt"},
+		{"encoded slash", "/assets%2f..%2f..%2fsecret.txt"},
+		{"double encoded", "/%252e%252e/secret.txt"},
+		{"double encoded slash", "/assets%252f%252e%252e%252fsecret.txt"},
+		{"backslash", "/..\\secret.txt"},
+		{"encoded backslash", "/..%5csecret.txt"},
+		{"mixed separators", "/assets\\../..%2fsecret.txt"},
+		{"dot file", "/.env"},
+		{"encoded dot file", "/%2eenv"},
+		{"dot file behind backslash", "/assets\\..\\.env"},
+	}
+
+	for env, handler := range handlers {
+		for _, tt := range tests {
+			t.Run(env+"/"+tt.name, func(t *testing.T) {
+				rec := serve(handler, http.MethodGet, tt.target)
+				if rec.Code != http.StatusNotFound {
+					t.Errorf("GET %s = %d, want 404", tt.target, rec.Code)
+				}
+
+				if body := rec.Body.String(); strings.Contains(body, "secret") {
+					t.Errorf("GET %s leaked %q", tt.target, body)
+				}
+			})
+		}
+	}
+}
+
+func TestFileServerAssetDirs(t *testing.T) {
+	vg := newProdVitGo(t, prodFS())
+	vg.SPAFallback = true
+	handler := fileServer(t, vg)
+
+	for _, target := range []string{"/assets/", "/assets/images/", "/assets"} {
+		rec := serve(handler, http.MethodGet, target)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", target, rec.Code)
+		}
+
+		if strings.Contains(rec.Body.String(), "<title>app</title>") {
+			t.Errorf("GET %s served the SPA index", target)
+		}
+	}
+
+	// other routes still get the SPA index
+	rec := serve(handler, http.MethodGet, "/dashboard/")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<title>app</title>") {
+		t.Errorf("GET /dashboard/ = %d %q, want the SPA index", rec.Code, rec.Body.String())
+	}
+}
+
+func TestFileServerMethods(t *testing.T) {
+	vg := newProdVitGo(t, prodFS())
+	vg.SPAFallback = true
+	handler := fileServer(t, vg)
+
+	targets := []string{"/", "/assets/main-4f2a1b.js", "/dashboard", "/missing.js"}
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions} {
+		for _, target := range targets {
+			rec := serve(handler, method, target)
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Errorf("%s %s = %d, want 405", method, target, rec.Code)
+			}
+
+			if allow := rec.Header().Get("Allow"); allow != "GET, HEAD" {
+				t.Errorf("%s %s Allow = %q, want GET, HEAD", method, target, allow)
+			}
+		}
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		for _, target := range []string{"/assets/main-4f2a1b.js", "/dashboard"} {
+			if rec := serve(handler, method, target); rec.Code != http.StatusOK {
+				t.Errorf("%s %s = %d, want 200", method, target, rec.Code)
+			}
+		}
+	}
+}
+
+func BenchmarkFileServer(b *testing.B) {
+	handler := fileServer(b, newProdVitGo(b, prodFS()))
+	req := httptest.NewRequest(http.MethodGet, "/assets/main-4f2a1b.js", nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			b.Fatalf("GET %s = %d, want 200", req.URL.Path, rec.Code)
+		}
+	}
+}
+
+func TestFileServerManifestHidden(t *testing.T) {
+	legacy := prodFS()
+	delete(legacy, "dist/.vite/manifest.json")
+	legacy["dist/manifest.json"] = &fstest.MapFile{Data: []byte(testManifest)}
+
+	tests := []struct {
+		name   string
+		fsys   fstest.MapFS
+		target string
+	}{
+		{"plain", legacy, "/manifest.json"},
+		{"double slash", legacy, "//manifest.json"},
+		{"dot", legacy, "/./manifest.json"},
+		{"dot dot", legacy, "/x/../manifest.json"},
+		{"vite dir", prodFS(), "/.vite/manifest.json"},
+		{"vite dir, double slash", prodFS(), "/.vite//manifest.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(fileServer(t, newProdVitGo(t, tt.fsys)), http.MethodGet, tt.target)
+
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("GET %s = %d, want %d", tt.target, rec.Code, http.StatusNotFound)
+			}
+
+			if strings.Contains(rec.Body.String(), "main-4f2a1b.js") {
+				t.Errorf("GET %s leaked the manifest", tt.target)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package vitgo
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// hashedName matches Vite's content-hashed file names, such as
+// main.4f8a1c9d.js or index-BX3kF9a2.js. The greedy prefix makes
+// it take the last segment before the extension, so chunk names
+// with hyphens of their own (react-dom-Ab12cd34.js) match too.
+var hashedName = regexp.MustCompile(`^.*[.-]([A-Za-z0-9_-]{8,})\.[A-Za-z0-9]+$`)
+
+// isHashedAsset reports whether a file name carries a content
+// hash.
+func isHashedAsset(name string) bool {
+	_, ok := contentHash(name)
+	return ok
+}
+
+// contentHash returns the content hash in a file name. Long hex
+// runs always count; other 8+ character runs only count if they
+// hold a digit or capital, so plain words like "elements" are
+// not mistaken for hashes.
+func contentHash(name string) (string, bool) {
+	matches := hashedName.FindStringSubmatch(path.Base(name))
+	if matches == nil {
+		return "", false
+	}
+
+	hash := matches[1]
+
+	if strings.Trim(hash, "0123456789abcdef") == "" {
+		return hash, true
+	}
+
+	if len(hash) == 8 && strings.ContainsAny(hash, "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ") {
+		return hash, true
+	}
+
+	return "", false
+}
+
+// setCacheHeaders sets Cache-Control for a production file:
+// hashed assets are cached forever, anything else (index.html
+// included) is revalidated on every use.
+func (vg *VitGo) setCacheHeaders(w http.ResponseWriter, name string) {
+	if vg.DisableCacheHeaders {
+		return
+	}
+
+	if isHashedAsset(name) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+}
+
+// setDevCacheHeaders sets Cache-Control for a file served in
+// development. HTML, including a directory's index.html, is
+// never stored, as a cached page would keep pointing at scripts
+// from before a rebuild; other files get no header, as before.
+func (vg *VitGo) setDevCacheHeaders(w http.ResponseWriter, urlPath string) {
+	if vg.DisableCacheHeaders {
+		return
+	}
+
+	if strings.HasSuffix(urlPath, "/") || strings.EqualFold(path.Ext(urlPath), ".html") {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+}
@@ -0,0 +1,49 @@
+package vitgo
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestContentHash(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"assets/index-BX3kF9a2.js", "BX3kF9a2"},
+		{"assets/main.4f8a1c9d.js", "4f8a1c9d"},
+		{"assets/react-dom-Ab12cd34.js", "Ab12cd34"},
+		{"assets/plugin-vue_export-helper-DlAUqK2U.js", "DlAUqK2U"},
+		{"assets/vendor-react-router-dom-C3x_9Qp1.js", "C3x_9Qp1"},
+		{"assets/index-Ab-cd123.css", "Ab-cd123"},
+		{"assets/logo-a1b2c3d4e5f6.svg", "a1b2c3d4e5f6"},
+		{"index.html", ""},
+		{"assets/custom-elements.js", ""},
+		{"assets/my-long-component-name.js", ""},
+		{"favicon.ico", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, ok := contentHash(tt.name)
+			if hash != tt.hash || ok != (tt.hash != "") {
+				t.Errorf("contentHash(%q) = %q, %v, want %q", tt.name, hash, ok, tt.hash)
+			}
+		})
+	}
+}
+
+func TestCacheHeadersHyphenatedChunk(t *testing.T) {
+	fsys := prodFS()
+	fsys["dist/assets/react-dom-Ab12cd34.js"] = fsys["dist/assets/main-4f2a1b.js"]
+
+	rec := serve(fileServer(t, newProdVitGo(t, fsys)), http.MethodGet, "/assets/react-dom-Ab12cd34.js")
+
+	if got := rec.Header().Get("Cache-Control"); got != "public, max-age=31536000, immutable" {
+		t.Errorf("Cache-Control = %q, want immutable", got)
+	}
+
+	if got := rec.Header().Get("ETag"); got != `"Ab12cd34"` {
+		t.Errorf("ETag = %q, want the name's hash", got)
+	}
+}
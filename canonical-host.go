@@ -0,0 +1,43 @@
+package vitgo
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultHealthPaths are never redirected to the CanonicalHost.
+var DefaultHealthPaths = []string{"/healthz", "/readyz", "/livez", "/health"}
+
+// canonicalHost wraps next so requests to any host other than
+// CanonicalHost get a 301 to the same path and query on it.
+// Health and readiness paths are passed through untouched.
+func (vg *VitGo) canonicalHost(next http.Handler) http.Handler {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if vg.CanonicalHost == "" || strings.EqualFold(r.Host, vg.CanonicalHost) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		healthPaths := vg.HealthPaths
+		if healthPaths == nil {
+			healthPaths = DefaultHealthPaths
+		}
+
+		for _, healthPath := range healthPaths {
+			if r.URL.Path == healthPath {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		scheme := "http"
+		if isSecureRequest(r) {
+			scheme = "https"
+		}
+
+		target := scheme + "://" + vg.CanonicalHost + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	}
+
+	return http.HandlerFunc(handler)
+}
@@ -0,0 +1,46 @@
+package vitgo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCanonicalHostHandlers(t *testing.T) {
+	vg := newProdVitGo(t, prodFS())
+	vg.CanonicalHost = "example.com"
+
+	app := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "<html><head></head></html>")
+	})
+
+	handlers := map[string]http.Handler{
+		"FileServer":           fileServer(t, vg),
+		"DevProxyMiddleware":   vg.DevProxyMiddleware(app),
+		"InjectMiddleware":     vg.InjectMiddleware(app),
+		"CrossOriginIsolation": vg.CrossOriginIsolation(app),
+	}
+
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://www.example.com/about?x=1", nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "http://example.com/about?x=1" {
+				t.Errorf("www: %d to %q, want a 301 to http://example.com/about?x=1", rec.Code, rec.Header().Get("Location"))
+			}
+
+			for _, target := range []string{"http://example.com/about", "http://www.example.com/healthz"} {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+				if rec.Code == http.StatusMovedPermanently {
+					t.Errorf("%s was redirected to %q", target, rec.Header().Get("Location"))
+				}
+			}
+		})
+	}
+}
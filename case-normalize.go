@@ -0,0 +1,86 @@
+package vitgo
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Case normalization modes for asset requests.
+const (
+	CASE_REDIRECT = "redirect"
+	CASE_SERVE    = "serve"
+)
+
+// manifestFiles lists every file the manifest references.
+func manifestFiles(m Manifest) []string {
+	var files []string
+
+	for _, entry := range m {
+		if entry.File != "" {
+			files = append(files, entry.File)
+		}
+
+		files = append(files, entry.CSS...)
+		files = append(files, entry.Assets...)
+	}
+
+	return files
+}
+
+// buildCaseIndex maps the lowercased form of each manifest file
+// to its real name. Names that only differ by case are left
+// out, since there is no single canonical file for them.
+func buildCaseIndex(files []string) map[string]string {
+	index := map[string]string{}
+	ambiguous := map[string]bool{}
+
+	for _, file := range files {
+		lower := strings.ToLower(file)
+
+		if existing, ok := index[lower]; ok && existing != file {
+			ambiguous[lower] = true
+		}
+
+		index[lower] = file
+	}
+
+	for lower := range ambiguous {
+		delete(index, lower)
+	}
+
+	return index
+}
+
+// normalizeCase handles requests whose path (without its leading
+// slash) only matches a manifest file when ignoring case. It
+// either redirects to the canonical name or rewrites the request
+// to serve it, and reports whether the response was written.
+func (vg *VitGo) normalizeCase(w http.ResponseWriter, r *http.Request, rest string) bool {
+	vg = vg.reloaded()
+
+	if vg.CaseNormalization == "" || vg.caseIndex == nil {
+		return false
+	}
+
+	canonical, ok := vg.caseIndex[strings.ToLower(rest)]
+	if !ok || canonical == rest {
+		// unknown, or already canonical: a real case-sensitive
+		// name is never touched
+		return false
+	}
+
+	if vg.CaseNormalization == CASE_REDIRECT {
+		target := "/" + canonical
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+
+		return true
+	}
+
+	r.URL.Path = "/" + canonical
+
+	return false
+}
@@ -0,0 +1,351 @@
+package vitgo
+
+import (
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// precompressed describes a compressed sibling of a file.
+type precompressed struct {
+	encoding string
+	suffix   string
+}
+
+// precompressedVariants are tried in order of preference.
+var precompressedVariants = []precompressed{
+	{encoding: "br", suffix: ".br"},
+	{encoding: "gzip", suffix: ".gz"},
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header
+// allows encoding (q=0 means it is refused).
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+
+		q, found := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q=")
+		if !found {
+			return true
+		}
+
+		weight, err := strconv.ParseFloat(q, 64)
+
+		return err != nil || weight > 0
+	}
+
+	return false
+}
+
+// siblingPath returns where the precompressed variant of name
+// lives: from the sidecar when listed there, else name+suffix.
+func (vg *VitGo) siblingPath(name string, variant precompressed) string {
+	if entry, ok := vg.sidecarEntry(name); ok {
+		switch {
+		case variant.encoding == "br" && entry.Brotli != "":
+			return strings.TrimPrefix(entry.Brotli, "/")
+		case variant.encoding == "gzip" && entry.Gzip != "":
+			return strings.TrimPrefix(entry.Gzip, "/")
+		}
+	}
+
+	return name + variant.suffix
+}
+
+// siblingCache remembers whether each precompressed sibling
+// decoded cleanly, so a sibling is only checked once per build.
+type siblingCache struct {
+	mu      sync.Mutex
+	checked map[string]siblingCheck
+}
+
+// siblingCheck is the outcome of checking a sibling, for the
+// modtime and size it had then.
+type siblingCheck struct {
+	modTime time.Time
+	size    int64
+	valid   bool
+}
+
+// openSibling opens a precompressed sibling of a file of
+// originalSize bytes, for streaming; the caller closes it. It
+// fails, before anything is written, if the sibling is missing,
+// can't seek, or does not decode to the original's size.
+func (vg *VitGo) openSibling(fsys fs.FS, name, encoding string, originalSize int64) (io.ReadSeekCloser, fs.FileInfo, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	content, ok := f.(io.ReadSeekCloser)
+	if info.IsDir() || !ok {
+		f.Close()
+		return nil, nil, fs.ErrNotExist
+	}
+
+	if !vg.siblingValid(name, info, content, encoding, originalSize) {
+		f.Close()
+		return nil, nil, fs.ErrInvalid
+	}
+
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return content, info, nil
+}
+
+// siblingValid checks a sibling's contents, caching the result.
+// Siblings over MaxInlineOpBytes are not decoded, only checked
+// to be non-empty.
+func (vg *VitGo) siblingValid(name string, info fs.FileInfo, content io.Reader, encoding string, originalSize int64) bool {
+	if vg.siblingCache != nil {
+		vg.siblingCache.mu.Lock()
+		defer vg.siblingCache.mu.Unlock()
+
+		check, ok := vg.siblingCache.checked[name]
+		if ok && check.modTime.Equal(info.ModTime()) && check.size == info.Size() {
+			return check.valid
+		}
+	}
+
+	var valid bool
+	if vg.MaxInlineOpBytes > 0 && info.Size() > vg.MaxInlineOpBytes {
+		valid = info.Size() > 0
+	} else {
+		valid = decodesTo(content, info.Size(), encoding, originalSize)
+	}
+
+	if !valid {
+		vg.logger().Warn("vitgo: serving uncompressed file in place of broken sibling", "file", name, "encoding", encoding)
+	}
+
+	if vg.siblingCache != nil {
+		if vg.siblingCache.checked == nil {
+			vg.siblingCache.checked = map[string]siblingCheck{}
+		}
+
+		vg.siblingCache.checked[name] = siblingCheck{modTime: info.ModTime(), size: info.Size(), valid: valid}
+	}
+
+	return valid
+}
+
+// decodesTo reports whether content, of encodedSize bytes, is a
+// complete stream in encoding holding size bytes. gzip is
+// decoded in full, which catches truncation and corruption
+// through its CRC. There is no brotli decoder in the standard
+// library, so a .br only has to be non-empty.
+func decodesTo(content io.Reader, encodedSize int64, encoding string, size int64) bool {
+	if encodedSize == 0 {
+		return false
+	}
+
+	if encoding != "gzip" {
+		return true
+	}
+
+	gz, err := gzip.NewReader(content)
+	if err != nil {
+		return false
+	}
+
+	n, err := io.Copy(io.Discard, gz)
+
+	return err == nil && n == size
+}
+
+// servePrecompressed serves a .br or .gz sibling of the requested
+// file when the client accepts it, and reports whether it did.
+// Content-Encoding is only set once a sibling has been opened,
+// so a broken one falls back to the uncompressed file.
+func (vg *VitGo) servePrecompressed(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) bool {
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	info, err := fs.Stat(fsys, name)
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	acceptEncoding := r.Header.Get("Accept-Encoding")
+
+	for _, variant := range precompressedVariants {
+		if !acceptsEncoding(acceptEncoding, variant.encoding) {
+			continue
+		}
+
+		sibling := vg.siblingPath(name, variant)
+
+		content, siblingInfo, err := vg.openSibling(fsys, sibling, variant.encoding, info.Size())
+		if err != nil {
+			continue
+		}
+		defer content.Close()
+
+		w.Header().Set("Content-Type", vg.mimeType(name))
+		w.Header().Set("Content-Encoding", variant.encoding)
+		encodedETag(w, variant.encoding)
+
+		http.ServeContent(w, r, name, siblingInfo.ModTime(), content)
+
+		return true
+	}
+
+	return false
+}
+
+// mimeType returns the Content-Type name is served with.
+func (vg *VitGo) mimeType(name string) string {
+	ctype := vg.contentType(name)
+	if ctype == "" {
+		ctype = mime.TypeByExtension(path.Ext(name))
+	}
+
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+
+	return ctype
+}
+
+// COMPRESS_MIN_SIZE is the smallest file CompressOnTheFly
+// gzips; below it, gzip's overhead outweighs the savings.
+const COMPRESS_MIN_SIZE = 1024
+
+// compressibleTypes are the media types CompressOnTheFly gzips,
+// besides text/*.
+var compressibleTypes = map[string]bool{
+	"application/javascript": true,
+	"application/json":       true,
+	"image/svg+xml":          true,
+}
+
+// gzipPool recycles gzip writers across responses.
+var gzipPool = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+// gzipOnTheFly returns a writer that gzips the response to r
+// for name, when CompressOnTheFly is set, the client accepts
+// gzip, and the file is compressible and large enough.
+// Otherwise it returns w. The caller must Close the returned
+// writer when it is a *gzipWriter.
+func (vg *VitGo) gzipOnTheFly(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) http.ResponseWriter {
+	if !vg.CompressOnTheFly || r.Method != http.MethodGet ||
+		!acceptsEncoding(r.Header.Get("Accept-Encoding"), "gzip") {
+		return w
+	}
+
+	if name == "" {
+		name = "."
+	}
+
+	info, err := fs.Stat(fsys, name)
+	if err == nil && info.IsDir() {
+		name = path.Join(name, "index.html")
+		info, err = fs.Stat(fsys, name)
+	}
+
+	if err != nil || info.IsDir() || info.Size() < COMPRESS_MIN_SIZE {
+		return w
+	}
+
+	mediaType, _, _ := strings.Cut(vg.mimeType(name), ";")
+	mediaType = strings.TrimSpace(mediaType)
+
+	if !strings.HasPrefix(mediaType, "text/") && !compressibleTypes[mediaType] {
+		return w
+	}
+
+	// ranges of the gzipped bytes are not supported; the whole
+	// file is sent instead
+	r.Header.Del("Range")
+
+	// set now so conditional requests match the gzipped variant
+	encodedETag(w, "gzip")
+
+	return &gzipWriter{ResponseWriter: w}
+}
+
+// gzipWriter gzips what is written through it. Only a 200 is
+// compressed; other statuses (a 304, say) pass through as is.
+// WriteHeader is passed on, so a WriterWrapper underneath still
+// records the status.
+type gzipWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+// WriteHeader implements http.ResponseWriter for gzipWriter.
+func (w *gzipWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+
+	if status == http.StatusOK && w.Header().Get("Content-Encoding") == "" {
+		w.Header().Del("Content-Length")
+		w.Header().Del("Accept-Ranges")
+		w.Header().Set("Content-Encoding", "gzip")
+
+		w.gz = gzipPool.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter for gzipWriter.
+func (w *gzipWriter) Write(buf []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.gz == nil {
+		return w.ResponseWriter.Write(buf)
+	}
+
+	return w.gz.Write(buf)
+}
+
+// Close flushes the gzip stream and returns its writer to the
+// pool.
+func (w *gzipWriter) Close() error {
+	if w.gz == nil {
+		return nil
+	}
+
+	err := w.gz.Close()
+
+	w.gz.Reset(io.Discard)
+	gzipPool.Put(w.gz)
+	w.gz = nil
+
+	return err
+}
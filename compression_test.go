@@ -0,0 +1,184 @@
+package vitgo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// gzipped returns data gzipped.
+func gzipped(t testing.TB, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// getGzip requests target through handler, accepting gzip.
+func getGzip(handler http.Handler, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestPrecompressedSibling(t *testing.T) {
+	original := []byte(strings.Repeat("console.log('main');\n", 100))
+	valid := gzipped(t, original)
+
+	tests := []struct {
+		name        string
+		sibling     []byte
+		wantEncoded bool
+	}{
+		{"valid", valid, true},
+		{"not gzip", []byte("this is not gzip at all"), false},
+		{"empty", []byte{}, false},
+		{"truncated", valid[:len(valid)/2], false},
+		{"corrupt", append(append([]byte{}, valid[:len(valid)-8]...), 0, 0, 0, 0, 0, 0, 0, 0), false},
+		{"other file", gzipped(t, []byte("console.log('other')")), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := prodFS()
+			fsys["dist/assets/main-4f2a1b.js"] = &fstest.MapFile{Data: original}
+			fsys["dist/assets/main-4f2a1b.js.gz"] = &fstest.MapFile{Data: tt.sibling}
+
+			handler := fileServer(t, newProdVitGo(t, fsys))
+
+			// twice, the second time from the cache
+			for i := 0; i < 2; i++ {
+				rec := getGzip(handler, "/assets/main-4f2a1b.js")
+				if rec.Code != http.StatusOK {
+					t.Fatalf("GET = %d, want 200", rec.Code)
+				}
+
+				body := rec.Body.Bytes()
+
+				if encoding := rec.Header().Get("Content-Encoding"); tt.wantEncoded {
+					if encoding != "gzip" {
+						t.Fatalf("Content-Encoding = %q, want gzip", encoding)
+					}
+
+					gz, err := gzip.NewReader(bytes.NewReader(body))
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if body, err = io.ReadAll(gz); err != nil {
+						t.Fatal(err)
+					}
+				} else if encoding != "" {
+					t.Fatalf("Content-Encoding = %q, want none", encoding)
+				}
+
+				if !bytes.Equal(body, original) {
+					t.Errorf("body = %q, want the original file", body)
+				}
+			}
+		})
+	}
+}
+
+// countingFS counts the bytes read from files opened through it.
+type countingFS struct {
+	fs.FS
+	read *int64
+}
+
+func (c countingFS) Open(name string) (fs.File, error) {
+	f, err := c.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return countingFile{File: f, read: c.read}, nil
+}
+
+// countingFile is a seekable file counting what is read from it.
+type countingFile struct {
+	fs.File
+	read *int64
+}
+
+func (c countingFile) Read(p []byte) (int, error) {
+	n, err := c.File.Read(p)
+	*c.read += int64(n)
+
+	return n, err
+}
+
+func (c countingFile) Seek(offset int64, whence int) (int64, error) {
+	return c.File.(io.Seeker).Seek(offset, whence)
+}
+
+func TestPrecompressedSiblingStreamed(t *testing.T) {
+	original := []byte(strings.Repeat("console.log('main');\n", 100))
+
+	tests := []struct {
+		name     string
+		sibling  []byte
+		maxBytes int64
+	}{
+		{"checked once", gzipped(t, original), 0},
+		// over MaxInlineOpBytes the sibling is never decoded
+		{"over MaxInlineOpBytes", []byte("too big to check, served as is"), 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := prodFS()
+			fsys["dist/assets/main-4f2a1b.js"] = &fstest.MapFile{Data: original}
+			fsys["dist/assets/main-4f2a1b.js.gz"] = &fstest.MapFile{Data: tt.sibling}
+
+			var read int64
+
+			vg := newProdVitGo(t, fsys)
+			vg.DistFS = countingFS{FS: fsys, read: &read}
+			vg.MaxInlineOpBytes = tt.maxBytes
+
+			handler := fileServer(t, vg)
+
+			rec := getGzip(handler, "/assets/main-4f2a1b.js")
+			if rec.Header().Get("Content-Encoding") != "gzip" || !bytes.Equal(rec.Body.Bytes(), tt.sibling) {
+				t.Fatalf("GET = %q, %q, want the sibling", rec.Header().Get("Content-Encoding"), rec.Body.Bytes())
+			}
+
+			// validated: a HEAD reads nothing, a GET only what it sends
+			read = 0
+
+			req := httptest.NewRequest(http.MethodHead, "/assets/main-4f2a1b.js", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if read != 0 {
+				t.Errorf("HEAD read %d bytes, want 0", read)
+			}
+
+			getGzip(handler, "/assets/main-4f2a1b.js")
+
+			if read != int64(len(tt.sibling)) {
+				t.Errorf("GET read %d bytes, want the sibling's %d", read, len(tt.sibling))
+			}
+		})
+	}
+}
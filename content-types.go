@@ -0,0 +1,29 @@
+package vitgo
+
+import (
+	"path"
+	"strings"
+)
+
+// DefaultContentTypes are the Content-Type overrides used for
+// extensions not in VitGo.ContentTypes, for types some
+// platforms' mime tables get wrong.
+var DefaultContentTypes = map[string]string{
+	".mjs":  "text/javascript",
+	".wasm": "application/wasm",
+}
+
+// contentType returns the Content-Type override for name's
+// extension, or "" to leave it to the mime table.
+func (vg *VitGo) contentType(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if ext == "" {
+		return ""
+	}
+
+	if ctype, ok := vg.ContentTypes[ext]; ok {
+		return ctype
+	}
+
+	return DefaultContentTypes[ext]
+}
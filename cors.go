@@ -0,0 +1,59 @@
+package vitgo
+
+import (
+	"net/http"
+	"strings"
+)
+
+// applyCORS sets the CORS headers for requests from one of
+// AllowedOrigins, and reports whether the request was a
+// preflight, which is then fully answered. Without
+// AllowedOrigins nothing is touched.
+func (vg *VitGo) applyCORS(w http.ResponseWriter, r *http.Request) bool {
+	if len(vg.AllowedOrigins) == 0 {
+		return false
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+
+	allowed := ""
+	for _, candidate := range vg.AllowedOrigins {
+		if candidate == "*" {
+			allowed = "*"
+			break
+		}
+
+		if strings.EqualFold(strings.TrimSuffix(candidate, "/"), origin) {
+			allowed = origin
+			break
+		}
+	}
+
+	w.Header().Add("Vary", "Origin")
+
+	if allowed == "" {
+		return false
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", allowed)
+
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+
+	if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+		w.Header().Set("Access-Control-Allow-Headers", headers)
+	}
+
+	w.Header().Set("Access-Control-Max-Age", "600")
+	w.WriteHeader(http.StatusNoContent)
+
+	return true
+}
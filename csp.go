@@ -0,0 +1,205 @@
+package vitgo
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+)
+
+// DefaultCSPDirectives are the directives SuggestedCSP fills in
+// when VitGo.CSPDirectives is not set.
+var DefaultCSPDirectives = []string{"script-src", "style-src", "connect-src"}
+
+// SuggestedCSP returns a Content-Security-Policy value allowing
+// the scripts and styles RenderTags emits for entry ("" for the
+// main one): the CDN origin of an absolute BasePath, and in
+// development the dev server origin and its HMR websocket. Inline
+// scripts and styles are allowed by nonce on a copy from
+// WithNonce, and otherwise by hash: the React refresh preamble,
+// and stylesheets inlined under CSSInlineThreshold. For a page
+// rendered with RenderShellWithState use SuggestedCSPWithState.
+//
+// This is a starting point, not a complete policy: merge it with
+// your own directives (default-src, img-src, ...) before use.
+func (vg *VitGo) SuggestedCSP(entry string) (string, error) {
+	return vg.suggestedCSP(entry)
+}
+
+// SuggestedCSPWithState is SuggestedCSP for a page rendered with
+// RenderShellWithState(entry, state); without a nonce, the state
+// script is allowed by its hash, so it depends on state.
+func (vg *VitGo) SuggestedCSPWithState(entry string, state any) (string, error) {
+	script, err := stateScript(state)
+	if err != nil {
+		return "", err
+	}
+
+	return vg.suggestedCSP(entry, script)
+}
+
+// suggestedCSP builds SuggestedCSP, hashing inlineScripts along
+// with the inline content of the entry's tags.
+func (vg *VitGo) suggestedCSP(entry string, inlineScripts ...string) (string, error) {
+	if entry == vg.MainModule {
+		entry = ""
+	}
+
+	input, err := vg.forInput(entry)
+	if err != nil {
+		return "", err
+	}
+
+	directives := input.CSPDirectives
+	if len(directives) == 0 {
+		directives = DefaultCSPDirectives
+	}
+
+	var devOrigin, hmrOrigin string
+
+	origins, err := input.devOrigins()
+	if err != nil {
+		return "", err
+	}
+
+	if len(origins) == 2 {
+		devOrigin, hmrOrigin = origins[0], origins[1]
+	}
+
+	// sources shared by script-src and style-src
+	var assetSources []string
+
+	if origin := cdnOrigin(input.BasePath); origin != "" {
+		assetSources = append(assetSources, origin)
+	}
+
+	if devOrigin != "" {
+		assetSources = append(assetSources, devOrigin)
+	}
+
+	scriptSources := append([]string(nil), assetSources...)
+	styleSources := append([]string(nil), assetSources...)
+
+	if input.nonce != "" {
+		nonce := "'nonce-" + input.nonce + "'"
+		scriptSources = append(scriptSources, nonce)
+		styleSources = append(styleSources, nonce)
+	} else {
+		if preamble := input.reactPreambleScript(); preamble != "" {
+			inlineScripts = append(inlineScripts, preamble)
+		}
+
+		for _, script := range inlineScripts {
+			scriptSources = append(scriptSources, cspHash(script))
+		}
+
+		if input.Environment == "production" {
+			for _, file := range input.CSSModule {
+				if css := input.inlineCSS(file); css != "" {
+					styleSources = append(styleSources, cspHash(string(css)))
+				}
+			}
+		}
+	}
+
+	var policy []string
+
+	for _, directive := range directives {
+		sources := []string{"'self'"}
+
+		switch directive {
+		case "script-src":
+			sources = append(sources, scriptSources...)
+
+		case "style-src":
+			sources = append(sources, styleSources...)
+
+		case "connect-src":
+			if hmrOrigin != "" {
+				sources = append(sources, devOrigin, hmrOrigin)
+			}
+		}
+
+		policy = append(policy, directive+" "+strings.Join(sources, " "))
+	}
+
+	return strings.Join(policy, "; "), nil
+}
+
+// cdnOrigin returns the origin of an absolute BasePath, as a
+// CSP source, or "" for a local one.
+func cdnOrigin(basePath string) string {
+	if !isAbsoluteURL(basePath) {
+		return ""
+	}
+
+	base, err := url.Parse(basePath)
+	if err != nil || base.Host == "" {
+		return ""
+	}
+
+	if base.Scheme == "" {
+		// protocol-relative, so whatever scheme the page has
+		return base.Host
+	}
+
+	return base.Scheme + "://" + base.Host
+}
+
+// cspHash returns the CSP hash source for an inline script or
+// style with the given text.
+func cspHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+
+	return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+}
+
+// DevScriptSources lists the origins dev tags load from: the dev
+// server's http(s) origin, for script-src, and its ws(s) HMR
+// origin, for connect-src. It is empty outside development.
+func (vg *VitGo) DevScriptSources() []string {
+	sources, err := vg.started().devOrigins()
+	if err != nil {
+		return nil
+	}
+
+	return sources
+}
+
+// devOrigins returns the dev server origin and the HMR origin.
+func (vg *VitGo) devOrigins() ([]string, error) {
+	if vg.Environment != "development" || vg.BaseURL == "" {
+		return nil, nil
+	}
+
+	base, err := url.Parse(vg.BaseURL)
+	if vg.DevClientURL != "" {
+		// the client, and HMR with it, come from elsewhere
+		base, err = url.Parse(vg.DevClientURL)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	hmrScheme := "ws"
+	if base.Scheme == "https" {
+		hmrScheme = "wss"
+	}
+
+	hmrOrigin := hmrScheme + "://" + base.Host
+
+	if vg.DevClientURL == "" && vg.HMRWebSocketURL != "" {
+		hmr, err := url.Parse(vg.HMRWebSocketURL)
+		if err != nil {
+			return nil, err
+		}
+
+		hmrOrigin = hmr.Scheme + "://" + hmr.Host
+	}
+
+	return []string{
+		base.Scheme + "://" + base.Host,
+		hmrOrigin,
+	}, nil
+}
@@ -0,0 +1,155 @@
+package vitgo
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var (
+	inlineScript = regexp.MustCompile(`(?s)<script[^>]*>(.*?)</script>`)
+	inlineStyle  = regexp.MustCompile(`(?s)<style[^>]*>(.*?)</style>`)
+)
+
+// inlineHashes returns the CSP hashes of the non-empty inline
+// bodies pattern finds in html.
+func inlineHashes(pattern *regexp.Regexp, html template.HTML) []string {
+	var hashes []string
+
+	for _, match := range pattern.FindAllStringSubmatch(string(html), -1) {
+		if match[1] != "" {
+			hashes = append(hashes, cspHash(match[1]))
+		}
+	}
+
+	return hashes
+}
+
+// directive returns the sources of name in policy.
+func directive(policy, name string) string {
+	for _, part := range strings.Split(policy, "; ") {
+		if sources, ok := strings.CutPrefix(part, name+" "); ok {
+			return sources
+		}
+	}
+
+	return ""
+}
+
+// cspFS is prodFS with a second entry whose stylesheet is small
+// enough to inline.
+func cspFS() fstest.MapFS {
+	fsys := prodFS()
+	fsys["dist/.vite/manifest.json"] = &fstest.MapFile{Data: []byte(`{
+		"src/main.tsx": {"file": "assets/main-4f2a1b.js", "src": "src/main.tsx", "isEntry": true, "css": ["assets/main-9c8d7e.css"]},
+		"src/admin.tsx": {"file": "assets/admin-1a2b3c.js", "src": "src/admin.tsx", "isEntry": true, "css": ["assets/admin-5e6f7a.css"]}
+	}`)}
+	fsys["dist/assets/main-9c8d7e.css"] = &fstest.MapFile{Data: []byte(strings.Repeat("body{margin:0}", 100))}
+	fsys["dist/assets/admin-1a2b3c.js"] = &fstest.MapFile{Data: []byte("console.log('admin')")}
+	fsys["dist/assets/admin-5e6f7a.css"] = &fstest.MapFile{Data: []byte(".admin{color:red}")}
+
+	return fsys
+}
+
+func TestSuggestedCSPProduction(t *testing.T) {
+	vg := newProdVitGo(t, cspFS())
+	vg.BasePath = "https://cdn.example.com/app/"
+	vg.CSSInlineThreshold = 512
+
+	for _, entry := range []string{"", "src/admin.tsx"} {
+		policy, err := vg.SuggestedCSP(entry)
+		if err != nil {
+			t.Fatalf("SuggestedCSP(%q): %v", entry, err)
+		}
+
+		tags, err := vg.RenderTags(entry)
+		if err != nil {
+			t.Fatalf("RenderTags(%q): %v", entry, err)
+		}
+
+		for _, name := range []string{"script-src", "style-src"} {
+			if !strings.Contains(directive(policy, name), "https://cdn.example.com") {
+				t.Errorf("%q: %s = %q, want the CDN origin", entry, name, directive(policy, name))
+			}
+		}
+
+		styles := inlineHashes(inlineStyle, tags)
+		for _, hash := range styles {
+			if !strings.Contains(directive(policy, "style-src"), hash) {
+				t.Errorf("%q: style-src = %q, missing inline style %s", entry, directive(policy, "style-src"), hash)
+			}
+		}
+
+		// only the admin stylesheet is small enough to inline
+		if want := map[string]int{"": 0, "src/admin.tsx": 1}[entry]; len(styles) != want {
+			t.Errorf("%q: %d inline styles, want %d", entry, len(styles), want)
+		}
+
+		if n := strings.Count(policy, "'sha256-"); n != len(styles) {
+			t.Errorf("%q: policy %q has %d hashes, want %d", entry, policy, n, len(styles))
+		}
+	}
+}
+
+func TestSuggestedCSPNonce(t *testing.T) {
+	vg := newProdVitGo(t, cspFS())
+	vg.CSSInlineThreshold = 512
+
+	policy, err := vg.WithNonce("r4nd0m").SuggestedCSP("src/admin.tsx")
+	if err != nil {
+		t.Fatalf("SuggestedCSP: %v", err)
+	}
+
+	for _, name := range []string{"script-src", "style-src"} {
+		if sources := directive(policy, name); !strings.Contains(sources, "'nonce-r4nd0m'") || strings.Contains(sources, "'sha256-") {
+			t.Errorf("%s = %q, want the nonce and no hashes", name, sources)
+		}
+	}
+}
+
+func TestSuggestedCSPDevelopment(t *testing.T) {
+	vg, err := NewVitGo(&ViteConfig{
+		FS: fstest.MapFS{
+			"frontend/package.json": {Data: []byte(`{"dependencies": {"react": "^18.2.0"}, "devDependencies": {"vite": "^5.0.0"}}`)},
+		},
+		Environment:    "development",
+		DisableLogging: true,
+	})
+	if err != nil {
+		t.Fatalf("NewVitGo: %v", err)
+	}
+
+	state := map[string]any{"user": "</script><script>alert(1)</script>"}
+
+	policy, err := vg.SuggestedCSPWithState("", state)
+	if err != nil {
+		t.Fatalf("SuggestedCSPWithState: %v", err)
+	}
+
+	shell, err := vg.RenderShellWithState("", state)
+	if err != nil {
+		t.Fatalf("RenderShellWithState: %v", err)
+	}
+
+	hashes := append(inlineHashes(inlineScript, vg.ReactRefreshPreamble()), inlineHashes(inlineScript, shell)...)
+	if len(hashes) != 2 {
+		t.Fatalf("found %d inline scripts, want the preamble and the state", len(hashes))
+	}
+
+	scriptSrc := directive(policy, "script-src")
+	for _, hash := range hashes {
+		if !strings.Contains(scriptSrc, hash) {
+			t.Errorf("script-src = %q, missing %s", scriptSrc, hash)
+		}
+	}
+
+	if !strings.Contains(scriptSrc, vg.BaseURL) {
+		t.Errorf("script-src = %q, want the dev server %s", scriptSrc, vg.BaseURL)
+	}
+
+	if connect := directive(policy, "connect-src"); !strings.Contains(connect, "ws://localhost:") {
+		t.Errorf("connect-src = %q, want the HMR websocket", connect)
+	}
+}
@@ -0,0 +1,144 @@
+package vitgo
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// devProxyTracker keeps track of the dev proxy's upstream
+// connections and in-flight requests, so CloseDevProxy can
+// drain them. HMR websockets hold on to their connection for as
+// long as the page is open, which would otherwise block a
+// graceful shutdown.
+type devProxyTracker struct {
+	transport *http.Transport
+
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	inflight int
+}
+
+// newDevProxyTracker returns a tracker with its own transport.
+func newDevProxyTracker() *devProxyTracker {
+	tracker := &devProxyTracker{
+		conns: map[net.Conn]struct{}{},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		return tracker.track(conn), nil
+	}
+
+	tracker.transport = transport
+
+	return tracker
+}
+
+// track registers conn until it is closed.
+func (t *devProxyTracker) track(conn net.Conn) net.Conn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.conns[conn] = struct{}{}
+
+	return &trackedConn{Conn: conn, tracker: t}
+}
+
+// wrap counts next's requests as in flight while they run.
+func (t *devProxyTracker) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.mu.Lock()
+		t.inflight++
+		t.mu.Unlock()
+
+		defer func() {
+			t.mu.Lock()
+			t.inflight--
+			t.mu.Unlock()
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// idle reports whether no proxied request is running.
+func (t *devProxyTracker) idle() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.inflight == 0
+}
+
+// closeAll force-closes every upstream connection.
+func (t *devProxyTracker) closeAll() {
+	t.mu.Lock()
+	conns := make([]net.Conn, 0, len(t.conns))
+	for conn := range t.conns {
+		conns = append(conns, conn)
+	}
+	t.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// trackedConn forgets itself once closed.
+type trackedConn struct {
+	net.Conn
+	tracker *devProxyTracker
+	once    sync.Once
+}
+
+// Close implements net.Conn for trackedConn.
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		c.tracker.mu.Lock()
+		delete(c.tracker.conns, c.Conn)
+		c.tracker.mu.Unlock()
+	})
+
+	return c.Conn.Close()
+}
+
+// CloseDevProxy shuts down DevProxyHandler's upstream side: it
+// closes idle connections to the dev server, waits for proxied
+// requests (HMR websockets included) to finish, and once ctx is
+// done force-closes whatever is left, returning ctx's error.
+// Call it alongside http.Server.Shutdown.
+func (vg *VitGo) CloseDevProxy(ctx context.Context) error {
+	tracker := vg.devProxy
+	if tracker == nil {
+		return nil
+	}
+
+	tracker.transport.CloseIdleConnections()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for !tracker.idle() {
+		select {
+		case <-ctx.Done():
+			tracker.closeAll()
+			return ctx.Err()
+
+		case <-ticker.C:
+			tracker.transport.CloseIdleConnections()
+		}
+	}
+
+	tracker.closeAll()
+
+	return nil
+}
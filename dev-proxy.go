@@ -1,11 +1,21 @@
 package vitgo
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"strings"
+	"time"
 )
 
+// devProxyPrefixes are the paths Vite's dev server answers
+// itself, besides URLPrefix.
+var devProxyPrefixes = []string{"/@vite/", "/@id/", "/@fs/"}
+
 // Redirector for dev server
 func (vg *VitGo) DevServerRedirector() http.Handler {
 	handler := func(w http.ResponseWriter, r *http.Request) {
@@ -22,11 +32,229 @@ func (vg *VitGo) DevServerRedirector() http.Handler {
 		escapedRest := strings.Replace(rest, "\n", "", -1)
 		escapedRest = strings.Replace(escapedRest, "\r", "", -1)
 
-		log.Println("rest: ", escapedRest)
+		vg.logger().Debug("dev server redirect", "path", escapedRest)
 
 		w.Header().Set("Content-Type", "application/javascript")
 		http.Redirect(w, r, vg.DevServer+rest, http.StatusPermanentRedirect)
 	}
 
-	return http.HandlerFunc(handler)
+	return vg.canonicalHost(http.HandlerFunc(handler))
+}
+
+// DevProxyHandler forwards requests for Vite-owned paths
+// (URLPrefix, /@vite/, /@id/ and /@fs/) to the dev server, so
+// the browser gets Vite's transformed modules rather than raw
+// files. Vite's HMR websocket is proxied too, to HMRURL, and
+// pinged every HMRPingInterval; with HMRSecure set, the proxied
+// Vite client opens it as HMRWebSocketURL's scheme says.
+// Anything else gets a 404; use DevProxyMiddleware to pass it on
+// to the rest of the app instead.
+func (vg *VitGo) DevProxyHandler() http.Handler {
+	return vg.DevProxyMiddleware(nil)
+}
+
+// DevProxyMiddleware is DevProxyHandler in front of next, which
+// gets every request that is not Vite's. Requests to a host other
+// than CanonicalHost are redirected first. A BaseURL or HMRURL
+// that does not parse is logged, and every request then gets a
+// 502.
+func (vg *VitGo) DevProxyMiddleware(next http.Handler) http.Handler {
+	handler, err := vg.devProxyHandler(next)
+	if err != nil {
+		vg.logger().Error("vitgo: could not proxy the dev server", "error", err)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		})
+	}
+
+	return handler
+}
+
+// devProxyHandler builds DevProxyMiddleware's handler.
+func (vg *VitGo) devProxyHandler(next http.Handler) (http.Handler, error) {
+	// on vg itself, as started may return a copy that
+	// CloseDevProxy never sees
+	if vg.devProxy == nil {
+		vg.devProxy = newDevProxyTracker()
+	}
+
+	vg = vg.started()
+
+	target, err := url.Parse(vg.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	hmrTarget := target
+	if vg.HMRURL != "" {
+		hmrTarget, err = url.Parse(vg.HMRURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if next == nil {
+		next = http.NotFoundHandler()
+	}
+
+	upstream := vg.devProxy.wrap(vg.newDevProxy(target))
+
+	hmrUpstream := upstream
+	if hmrTarget.Host != target.Host {
+		// the browser already asks for HMRPath
+		origin := &url.URL{Scheme: hmrTarget.Scheme, Host: hmrTarget.Host}
+		hmrUpstream = vg.devProxy.wrap(vg.newDevProxy(origin))
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if isHMRRequest(r, hmrTarget.Path) {
+			hmrUpstream.ServeHTTP(w, r)
+			return
+		}
+
+		if vg.isDevServerPath(r.URL.Path) {
+			// Vite also answers CORS preflights itself
+			if r.Method != http.MethodOptions && !allowMethod(w, r) {
+				return
+			}
+
+			upstream.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return vg.canonicalHost(http.HandlerFunc(handler)), nil
+}
+
+// newDevProxy returns a reverse proxy to the dev server at
+// target.
+func (vg *VitGo) newDevProxy(target *url.URL) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = vg.devProxy.transport
+	proxy.FlushInterval = -1
+	proxy.ErrorLog = slog.NewLogLogger(vg.logger().Handler(), slog.LevelError)
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		if err := vg.rewriteViteClient(resp); err != nil {
+			return err
+		}
+
+		return vg.pingWebSocket(resp)
+	}
+
+	// Vite checks the Host header against its own allowed hosts.
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = target.Host
+
+		// the client is rewritten, so let the transport ask for
+		// gzip and decode it
+		if vg.hmrProtocol() != "" && isViteClientRequest(r) {
+			r.Header.Del("Accept-Encoding")
+		}
+	}
+
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		vg.logger().Error("dev server proxy error", "path", r.URL.Path, "error", err)
+
+		if vg.FriendlyDevErrors {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprintf(w, devServerDownPage, html.EscapeString(target.Host))
+
+			return
+		}
+
+		w.WriteHeader(http.StatusBadGateway)
+	}
+
+	return proxy
+}
+
+// isHMRRequest reports whether r opens Vite's HMR websocket:
+// an upgrade under hmrPath when one is configured, else one
+// asking for Vite's vite-hmr subprotocol.
+func isHMRRequest(r *http.Request, hmrPath string) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+
+	if hmrPath != "" && hmrPath != "/" {
+		return strings.HasPrefix(r.URL.Path, hmrPath)
+	}
+
+	for _, protocol := range strings.Split(r.Header.Get("Sec-WebSocket-Protocol"), ",") {
+		if strings.TrimSpace(protocol) == "vite-hmr" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isDevServerPath reports whether a request path belongs to
+// the Vite dev server.
+func (vg *VitGo) isDevServerPath(urlPath string) bool {
+	if vg.URLPrefix != "" && vg.URLPrefix != "/" && strings.HasPrefix(urlPath, vg.URLPrefix) {
+		return true
+	}
+
+	for _, prefix := range devProxyPrefixes {
+		if strings.HasPrefix(urlPath, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// devServerDownPage is sent by DevProxyHandler, when
+// FriendlyDevErrors is set, if the dev server cannot be reached.
+const devServerDownPage = `<!doctype html>
+<html>
+<head><title>Vite dev server not reachable</title></head>
+<body>
+<h1>Vite dev server not reachable at %s</h1>
+<p>Did you run <code>npm run dev</code>?</p>
+</body>
+</html>
+`
+
+// WaitForDevServer polls the dev server until it answers or ctx
+// is done, backing off between attempts. Call it before serving
+// in development so the first requests don't fail while Vite is
+// still starting.
+func (vg *VitGo) WaitForDevServer(ctx context.Context) error {
+	vg = vg.started()
+
+	clientURL := strings.TrimSuffix(vg.BaseURL, "/") + "/@vite/client"
+	client := &http.Client{Timeout: 2 * time.Second}
+	delay := 100 * time.Millisecond
+
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, clientURL, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("dev server not reachable at %s: %w", vg.BaseURL, ctx.Err())
+
+		case <-time.After(delay):
+		}
+
+		if delay < 2*time.Second {
+			delay *= 2
+		}
+	}
 }
@@ -0,0 +1,143 @@
+package vitgo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// viteClient is the part of Vite's client that picks the HMR
+// websocket's scheme.
+const viteClient = `const importMetaUrl = new URL(import.meta.url);
+const socketProtocol = null || (importMetaUrl.protocol === "https:" ? "wss" : "ws");
+const hmrPort = null;
+`
+
+func TestDevProxyHMRSecure(t *testing.T) {
+	dev := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/javascript")
+		w.Header().Set("ETag", `"client"`)
+		io.WriteString(w, viteClient)
+	}))
+	defer dev.Close()
+
+	secure, insecure := true, false
+
+	tests := []struct {
+		name   string
+		secure *bool
+		want   string
+	}{
+		{"unset", nil, `const socketProtocol = null || (importMetaUrl.protocol === "https:" ? "wss" : "ws");`},
+		{"secure", &secure, `const socketProtocol = "wss";`},
+		{"insecure", &insecure, `const socketProtocol = "ws";`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vg, err := NewVitGo(&ViteConfig{
+				FS: fstest.MapFS{
+					"frontend/package.json": {Data: []byte(`{"devDependencies": {"vite": "^5.0.0"}}`)},
+				},
+				Environment:    "development",
+				HMRSecure:      tt.secure,
+				DisableLogging: true,
+			})
+			if err != nil {
+				t.Fatalf("NewVitGo: %v", err)
+			}
+
+			vg.BaseURL = dev.URL
+
+			handler := vg.DevProxyHandler()
+
+			req := httptest.NewRequest(http.MethodGet, viteClientPath, nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			body := rec.Body.String()
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("client = %q, want it to contain %q", body, tt.want)
+			}
+
+			if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(len(body)) {
+				t.Errorf("Content-Length = %s, body is %d bytes", got, len(body))
+			}
+
+			if tt.secure == nil {
+				return
+			}
+
+			if got := rec.Header().Get("ETag"); got != "" {
+				t.Errorf("rewritten client kept ETag %q", got)
+			}
+		})
+	}
+}
+
+func TestDevProxyMiddleware(t *testing.T) {
+	dev := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "vite "+r.URL.Path)
+	}))
+	defer dev.Close()
+
+	vg, err := NewVitGo(&ViteConfig{
+		FS: fstest.MapFS{
+			"frontend/package.json": {Data: []byte(`{"devDependencies": {"vite": "^5.0.0"}}`)},
+		},
+		Environment:    "development",
+		DisableLogging: true,
+	})
+	if err != nil {
+		t.Fatalf("NewVitGo: %v", err)
+	}
+
+	vg.BaseURL = dev.URL
+
+	app := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "app "+r.URL.Path)
+	})
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		target  string
+		status  int
+		body    string
+	}{
+		{"handler, vite path", vg.DevProxyHandler(), "/@vite/client", http.StatusOK, "vite /@vite/client"},
+		{"handler, other path", vg.DevProxyHandler(), "/about", http.StatusNotFound, ""},
+		{"middleware, vite path", vg.DevProxyMiddleware(app), "/@id/react", http.StatusOK, "vite /@id/react"},
+		{"middleware, other path", vg.DevProxyMiddleware(app), "/about", http.StatusOK, "app /about"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+
+			if tt.body != "" && rec.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.body)
+			}
+		})
+	}
+
+	vg.BaseURL = "http://[::1"
+
+	rec := httptest.NewRecorder()
+	vg.DevProxyMiddleware(app).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/about", nil))
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("bad BaseURL: status = %d, want %d", rec.Code, http.StatusBadGateway)
+	}
+}
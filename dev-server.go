@@ -0,0 +1,234 @@
+package vitgo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DEV_SERVER_START_TIMEOUT is how long StartDevServer waits
+// for Vite to report the address it listens on.
+const DEV_SERVER_START_TIMEOUT = 30 * time.Second
+
+// devServerAddress matches the address Vite prints on startup,
+// e.g. "Local:   http://localhost:5174/".
+var devServerAddress = regexp.MustCompile(`Local:\s+(https?://[^\s/]+)`)
+
+// ansiEscape matches terminal color codes in Vite's output.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// lockfileRunners picks the package manager running the dev
+// script from the project's lockfile; npm is the fallback.
+var lockfileRunners = []struct {
+	lockfile string
+	runner   string
+}{
+	{"pnpm-lock.yaml", "pnpm"},
+	{"yarn.lock", "yarn"},
+	{"bun.lockb", "bun"},
+	{"bun.lock", "bun"},
+}
+
+// devAddress holds the address StartDevServer found Vite on. It
+// lives behind a pointer so every copy of a VitGo sees it, and
+// is guarded as it may be set while others are rendering.
+type devAddress struct {
+	mu      sync.Mutex
+	baseURL string
+}
+
+// StartDevServer runs the Vite dev server in DevServerDir, or
+// else JSProjectPath; both are relative to the process's working
+// directory, not to FS. It runs DevServerCommand if set, else
+// package.json's dev script. Its output goes to the package
+// logger. The process lives until it exits or ctx is done; wait
+// on or kill the returned command.
+//
+// Vite picks another port when the configured one is taken, so
+// StartDevServer waits (up to DEV_SERVER_START_TIMEOUT) for
+// Vite to print its address, and the tags, DevProxyHandler and
+// WaitForDevServer then use it in place of BaseURL (see
+// DevServerURL). Create a DevProxyHandler after calling it.
+func (vg *VitGo) StartDevServer(ctx context.Context) (*exec.Cmd, error) {
+	command, err := vg.devServerCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+
+	cmd.Dir = vg.DevServerDir
+	if cmd.Dir == "" {
+		cmd.Dir = vg.JSProjectPath
+	}
+
+	// os.Pipe rather than StdoutPipe: exec neither copies from
+	// nor closes these, so reading them is not racing cmd.Wait,
+	// and they reach EOF once the process is gone.
+	stdout, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stderr, stderrWriter, err := os.Pipe()
+	if err != nil {
+		stdout.Close()
+		stdoutWriter.Close()
+
+		return nil, err
+	}
+
+	cmd.Stdout, cmd.Stderr = stdoutWriter, stderrWriter
+
+	err = cmd.Start()
+
+	// the process has its own copies now
+	stdoutWriter.Close()
+	stderrWriter.Close()
+
+	if err != nil {
+		stdout.Close()
+		stderr.Close()
+
+		return nil, err
+	}
+
+	vg.logger().Info("started dev server", "command", strings.Join(command, " "), "pid", cmd.Process.Pid)
+
+	address := make(chan string, 1)
+	done := make(chan struct{})
+
+	go vg.pipeDevServerOutput(stdout, slog.LevelInfo, address, done)
+	go vg.pipeDevServerOutput(stderr, slog.LevelWarn, nil, nil)
+
+	timer := time.NewTimer(DEV_SERVER_START_TIMEOUT)
+	defer timer.Stop()
+
+	select {
+	case origin := <-address:
+		if origin != vg.BaseURL {
+			vg.logger().Info("dev server address differs from config", "configured", vg.BaseURL, "actual", origin)
+		}
+
+		if vg.devAddress != nil {
+			vg.devAddress.mu.Lock()
+			vg.devAddress.baseURL = origin
+			vg.devAddress.mu.Unlock()
+		}
+
+	case <-done:
+		return cmd, errors.New("dev server exited before reporting its address")
+
+	case <-ctx.Done():
+		return cmd, ctx.Err()
+
+	case <-timer.C:
+		vg.logger().Warn("dev server did not report its address; assuming " + vg.BaseURL)
+	}
+
+	return cmd, nil
+}
+
+// DevServerURL returns the dev server's address: the one
+// StartDevServer found, or else BaseURL.
+func (vg *VitGo) DevServerURL() string {
+	return vg.started().BaseURL
+}
+
+// started returns vg with BaseURL set to the address
+// StartDevServer found, if it differs. vg itself is not
+// modified, so this is safe to call while serving.
+func (vg *VitGo) started() *VitGo {
+	if vg.devAddress == nil {
+		return vg
+	}
+
+	vg.devAddress.mu.Lock()
+	baseURL := vg.devAddress.baseURL
+	vg.devAddress.mu.Unlock()
+
+	if baseURL == "" || baseURL == vg.BaseURL {
+		return vg
+	}
+
+	updated := *vg
+	updated.BaseURL = baseURL
+
+	return &updated
+}
+
+// devServerCommand returns DevServerCommand, or the command
+// running package.json's dev script.
+func (vg *VitGo) devServerCommand() ([]string, error) {
+	if len(vg.DevServerCommand) > 0 {
+		return vg.DevServerCommand, nil
+	}
+
+	if vg.config == nil {
+		return nil, ErrNoPackageJSON
+	}
+
+	pkgJSON, err := vg.config.parsePackageJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := pkgJSON.Scripts["dev"]; !ok {
+		return nil, errors.New("package.json has no dev script")
+	}
+
+	runner := "npm"
+
+	projectFS, err := correctFS(vg.config.FS, vg.config.FSRoot, vg.config.JSProjectPath)
+	if err == nil {
+		for _, candidate := range lockfileRunners {
+			if _, err := fs.Stat(projectFS, candidate.lockfile); err == nil {
+				runner = candidate.runner
+				break
+			}
+		}
+	}
+
+	return []string{runner, "run", "dev"}, nil
+}
+
+// pipeDevServerOutput logs each line of the dev server's output
+// at level, and closes it at the end. The first address found is
+// sent on address; done is closed when the output ends.
+func (vg *VitGo) pipeDevServerOutput(output io.ReadCloser, level slog.Level, address chan<- string, done chan<- struct{}) {
+	defer output.Close()
+
+	if done != nil {
+		defer close(done)
+	}
+
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		line := ansiEscape.ReplaceAllString(scanner.Text(), "")
+
+		if strings.TrimSpace(line) != "" {
+			vg.logger().Log(context.Background(), level, "vite: "+line)
+		}
+
+		if address == nil {
+			continue
+		}
+
+		if matches := devServerAddress.FindStringSubmatch(line); matches != nil {
+			if origin, err := url.Parse(matches[1]); err == nil {
+				address <- origin.Scheme + "://" + origin.Host
+				address = nil
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package vitgo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// newDevVitGo returns a development VitGo for a React project
+// whose dev server is faked by script, run with sh.
+func newDevVitGo(t *testing.T, script string) *VitGo {
+	t.Helper()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh to fake the dev server with")
+	}
+
+	vg, err := NewVitGo(&ViteConfig{
+		FS: fstest.MapFS{
+			"frontend/package.json": {Data: []byte(`{"dependencies": {"react": "^18.2.0"}, "devDependencies": {"vite": "^5.0.0"}}`)},
+		},
+		Environment:    "development",
+		DisableLogging: true,
+	})
+	if err != nil {
+		t.Fatalf("NewVitGo: %v", err)
+	}
+
+	vg.DevServerCommand = []string{"sh", "-c", script}
+	vg.DevServerDir = t.TempDir()
+
+	return vg
+}
+
+func TestStartDevServerAddress(t *testing.T) {
+	vg := newDevVitGo(t, `echo "  VITE v5.0.0  ready"; printf '  \033[32m➜\033[0m  Local:   http://localhost:5199/\n'; echo warning >&2; sleep 30`)
+	configured := vg.BaseURL
+	copied := vg.WithNonce("n0nce")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	// rendering goes on while the address is published
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 50; i++ {
+			if _, err := vg.RenderTags(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	cmd, err := vg.StartDevServer(ctx)
+	if err != nil {
+		t.Fatalf("StartDevServer: %v", err)
+	}
+
+	wg.Wait()
+
+	if vg.BaseURL != configured {
+		t.Errorf("BaseURL = %q, want the configured %q kept", vg.BaseURL, configured)
+	}
+
+	for name, v := range map[string]*VitGo{"vg": vg, "copy": copied} {
+		if got := v.DevServerURL(); got != "http://localhost:5199" {
+			t.Errorf("%s.DevServerURL() = %q, want http://localhost:5199", name, got)
+		}
+
+		tags, err := v.RenderTags()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(tags), `src="http://localhost:5199/@vite/client"`) {
+			t.Errorf("%s.RenderTags() = %s, want the client from port 5199", name, tags)
+		}
+	}
+
+	cancel()
+	cmd.Wait()
+}
+
+func TestStartDevServerExits(t *testing.T) {
+	vg := newDevVitGo(t, `echo "error: vite not found" >&2; exit 1`)
+
+	cmd, err := vg.StartDevServer(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "exited") {
+		t.Fatalf("StartDevServer = %v, want an exit error", err)
+	}
+
+	if err := cmd.Wait(); err == nil {
+		t.Error("Wait = nil, want the exit status")
+	}
+
+	if got := vg.DevServerURL(); got != vg.BaseURL {
+		t.Errorf("DevServerURL() = %q, want BaseURL %q", got, vg.BaseURL)
+	}
+}
+
+func TestStartDevServerCloseDevProxy(t *testing.T) {
+	requested := make(chan struct{})
+	release := make(chan struct{})
+
+	dev := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requested)
+
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer dev.Close()
+	defer close(release)
+
+	vg := newDevVitGo(t, `echo "  Local:   `+dev.URL+`/"; sleep 30`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cmd, err := vg.StartDevServer(ctx)
+	if err != nil {
+		t.Fatalf("StartDevServer: %v", err)
+	}
+
+	defer func() {
+		cancel()
+		cmd.Wait()
+	}()
+
+	if vg.DevServerURL() != dev.URL {
+		t.Fatalf("DevServerURL() = %q, want %q", vg.DevServerURL(), dev.URL)
+	}
+
+	app := httptest.NewServer(vg.DevProxyHandler())
+	defer app.Close()
+	defer app.CloseClientConnections()
+
+	done := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(app.URL + "/@vite/client")
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+
+	<-requested
+
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer closeCancel()
+
+	// the proxied request is still running, so it must be waited
+	// for and then cut off
+	if err := vg.CloseDevProxy(closeCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CloseDevProxy = %v, want context.DeadlineExceeded", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("proxied request still running after CloseDevProxy")
+	}
+}
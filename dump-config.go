@@ -0,0 +1,69 @@
+package vitgo
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// CONFIG_DUMP_PATH is where the file server answers with
+// DumpConfig's output when Debug is set.
+const CONFIG_DUMP_PATH = "/__vitgo/config"
+
+// configDump is the JSON form of a resolved ViteConfig.
+type configDump struct {
+	Environment   string            `json:"environment"`
+	Platform      string            `json:"platform"`
+	EntryPoint    string            `json:"entry_point"`
+	URLPrefix     string            `json:"url_prefix"`
+	JSProjectPath string            `json:"js_project_path"`
+	AssetsPath    string            `json:"assets_path,omitempty"`
+	ViteVersion   string            `json:"vite_version"`
+	DevServerURL  string            `json:"dev_server_url,omitempty"`
+	HMRURL        string            `json:"hmr_url,omitempty"`
+	HMRSocketURL  string            `json:"hmr_websocket_url,omitempty"`
+	Inputs        map[string]string `json:"inputs,omitempty"`
+	DevDefaults   *JSAppParams      `json:"dev_defaults,omitempty"`
+}
+
+// DumpConfig returns the effective configuration as indented
+// JSON, including what was detected from package.json, to see
+// what the defaults resolved to.
+func (vc *ViteConfig) DumpConfig() ([]byte, error) {
+	dump := configDump{
+		Environment:   vc.Environment,
+		Platform:      vc.Platform,
+		EntryPoint:    vc.EntryPoint,
+		URLPrefix:     vc.URLPrefix,
+		JSProjectPath: vc.JSProjectPath,
+		AssetsPath:    vc.AssetsPath,
+		ViteVersion:   vc.ViteVersion,
+		Inputs:        vc.Inputs,
+		DevDefaults:   vc.DevDefaults,
+	}
+
+	if vc.Environment != "production" {
+		dump.DevServerURL = vc.buildDevServerBaseURL()
+		dump.HMRURL = vc.buildHMRURL()
+		dump.HMRSocketURL = vc.buildHMRWebSocketURL()
+	}
+
+	return json.MarshalIndent(dump, "", "  ")
+}
+
+// serveConfigDump writes the config NewVitGo resolved.
+func (vg *VitGo) serveConfigDump(w http.ResponseWriter, r *http.Request) {
+	if vg.config == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	buf, err := vg.config.DumpConfig()
+	if err != nil {
+		vg.logger().Error("could not dump config", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	vg.serveOneFile(w, r, buf, "application/json")
+}
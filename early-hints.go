@@ -0,0 +1,69 @@
+package vitgo
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// EarlyHints sends a 103 Early Hints response with preload Link
+// headers for entry's script, imports and stylesheets, so the
+// browser can fetch them while the page is still being
+// rendered. Call it before writing the real response. It does
+// nothing in development, where Vite serves modules on demand.
+//
+// Clients that do not understand 103 ignore it, and the Link
+// headers are repeated on the final response.
+func (vg *VitGo) EarlyHints(w http.ResponseWriter, entry string) error {
+	if vg.Environment != "production" {
+		return nil
+	}
+
+	input, err := vg.forInput(entry)
+	if err != nil {
+		return err
+	}
+
+	links := input.earlyHintLinks()
+	if len(links) == 0 {
+		return nil
+	}
+
+	for _, link := range links {
+		w.Header().Add("Link", link)
+	}
+
+	w.WriteHeader(http.StatusEarlyHints)
+
+	return nil
+}
+
+// earlyHintLinks returns the Link header values for vg's entry.
+func (vg *VitGo) earlyHintLinks() []string {
+	var links []string
+
+	// a hint is only reused by a fetch with the same crossorigin
+	// mode, so each takes it from the tag that fetches the file
+	if vg.MainModule != "" && !vg.SSRNoClient {
+		links = append(links, "<"+vg.AssetURL(vg.MainModule)+">; rel=modulepreload"+hintCrossorigin(vg.scriptCrossorigin()))
+	}
+
+	for _, file := range vg.preloads() {
+		links = append(links, "<"+vg.AssetURL(file)+">; rel=modulepreload"+hintCrossorigin(vg.preloadCrossorigin()))
+	}
+
+	for _, file := range vg.CSSModule {
+		links = append(links, "<"+vg.AssetURL(file)+">; rel=preload; as=style"+hintCrossorigin(vg.preloadCrossorigin()))
+	}
+
+	return links
+}
+
+// hintCrossorigin turns a tag's crossorigin attribute into the
+// matching Link header parameter.
+func hintCrossorigin(attr template.HTMLAttr) string {
+	if attr == "" {
+		return ""
+	}
+
+	return "; crossorigin"
+}
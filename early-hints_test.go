@@ -0,0 +1,70 @@
+package vitgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEarlyHintsMatchTags(t *testing.T) {
+	on, off := true, false
+
+	tests := []struct {
+		name        string
+		basePath    string
+		crossorigin *bool
+	}{
+		{"default", "", nil},
+		{"cdn", "https://cdn.example.com/", nil},
+		{"forced on", "", &on},
+		{"forced off", "https://cdn.example.com/", &off},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vg := newInputsVitGo(t)
+			vg.BasePath = tt.basePath
+			vg.Crossorigin = tt.crossorigin
+
+			tags, err := vg.RenderTags()
+			if err != nil {
+				t.Fatalf("RenderTags: %v", err)
+			}
+
+			rec := httptest.NewRecorder()
+			if err := vg.EarlyHints(rec, ""); err != nil {
+				t.Fatalf("EarlyHints: %v", err)
+			}
+
+			if rec.Code != http.StatusEarlyHints {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusEarlyHints)
+			}
+
+			links := rec.Header().Values("Link")
+			if len(links) != 6 {
+				t.Errorf("Link = %q, want the script, 2 chunks and 3 stylesheets", links)
+			}
+
+			for _, link := range links {
+				url, _, _ := strings.Cut(strings.TrimPrefix(link, "<"), ">")
+
+				var tag string
+				for _, line := range strings.Split(string(tags), "\n") {
+					if strings.Contains(line, `"`+url+`"`) {
+						tag = line
+					}
+				}
+
+				if tag == "" {
+					t.Errorf("hint %q has no rendered tag", link)
+					continue
+				}
+
+				if hinted, tagged := strings.HasSuffix(link, "; crossorigin"), strings.Contains(tag, " crossorigin"); hinted != tagged {
+					t.Errorf("hint %q crossorigin = %v, tag %q crossorigin = %v", link, hinted, tag, tagged)
+				}
+			}
+		})
+	}
+}
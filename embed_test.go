@@ -0,0 +1,69 @@
+package vitgo
+
+import (
+	"embed"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// The all: prefix keeps the .vite directory holding the manifest.
+//
+//go:embed all:testdata/nested
+var nestedFS embed.FS
+
+func TestEmbedNestedProject(t *testing.T) {
+	tests := []struct {
+		name        string
+		projectPath string
+		assetsPath  string
+	}{
+		{"slashes", "testdata/nested/web/frontend/app", "build/dist"},
+		{"dot and trailing slash", "./testdata/nested/web/frontend/app/", "./build/dist/"},
+		{"backslashes", "testdata\\nested\\web\\frontend\\app", "build\\dist"},
+		{"mixed separators", ".\\testdata/nested\\web/frontend/app\\", "build\\dist/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vg, err := NewVitGo(&ViteConfig{
+				FS:             nestedFS,
+				JSProjectPath:  tt.projectPath,
+				AssetsPath:     tt.assetsPath,
+				Environment:    "production",
+				Platform:       "react",
+				DisableLogging: true,
+			})
+			if err != nil {
+				t.Fatalf("NewVitGo: %v", err)
+			}
+
+			if vg.MainModule != "assets/main-4f2a1b.js" {
+				t.Errorf("MainModule = %q, want assets/main-4f2a1b.js", vg.MainModule)
+			}
+
+			handler := fileServer(t, vg)
+
+			rec := serve(handler, http.MethodGet, "/assets/main-4f2a1b.js")
+			if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "console.log") {
+				t.Errorf("GET /assets/main-4f2a1b.js = %d %q", rec.Code, rec.Body.String())
+			}
+
+			rec = serve(handler, http.MethodGet, "/")
+			if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<title>nested</title>") {
+				t.Errorf("GET / = %d %q", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestEmbedNestedProjectDevelopment(t *testing.T) {
+	config, err := NewConfigFromFS(nestedFS, ".\\testdata\\nested\\web\\frontend\\app\\")
+	if err != nil {
+		t.Fatalf("NewConfigFromFS: %v", err)
+	}
+
+	if config.Platform != "react" {
+		t.Errorf("Platform = %q, want react", config.Platform)
+	}
+}
@@ -0,0 +1,58 @@
+package vitgo
+
+import (
+	"os"
+	"strings"
+)
+
+// environmentVars are read, in order, by ResolveEnvironment.
+var environmentVars = []string{"VITGO_ENV", "APP_ENV", "GO_ENV"}
+
+// normalizeEnvironment maps the usual spellings of an
+// environment onto development or production. It returns ""
+// for anything else.
+func normalizeEnvironment(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "prod", "production":
+		return "production"
+	case "dev", "develop", "development":
+		return "development"
+	}
+
+	return ""
+}
+
+// ResolveEnvironment fills in Environment when it is empty, from
+// VITGO_ENV, then APP_ENV, then GO_ENV, defaulting to
+// development, so the same binary can run in either mode. A
+// variable holding something other than a known spelling of
+// development or production is skipped with a warning. An
+// Environment set in code is kept, though "prod" and "dev" are
+// normalized.
+func (vc *ViteConfig) ResolveEnvironment() string {
+	if vc.Environment != "" {
+		if normalized := normalizeEnvironment(vc.Environment); normalized != "" {
+			vc.Environment = normalized
+		}
+
+		return vc.Environment
+	}
+
+	for _, name := range environmentVars {
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			continue
+		}
+
+		if normalized := normalizeEnvironment(value); normalized != "" {
+			vc.Environment = normalized
+			return vc.Environment
+		}
+
+		vc.logger().Warn("vitgo: ignoring unknown environment", "variable", name, "value", value)
+	}
+
+	vc.Environment = "development"
+
+	return vc.Environment
+}
@@ -7,4 +7,16 @@ var (
 	ErrNoInputFile         = errors.New("expected import file name")
 	ErrManifestBadlyFormed = errors.New("manifest has unexpected format")
 	ErrManifestDNF         = errors.New("vue distribution directory not found")
+	ErrBadLoadStrategy     = errors.New("unknown script load strategy")
+	ErrBadRootBehavior     = errors.New("unknown root behavior")
+	ErrSidecarBadlyFormed  = errors.New("sidecar has unexpected format")
+	ErrUnknownEntry        = errors.New("entry point not known")
+	ErrIntegrityMismatch   = errors.New("file does not match its integrity hash")
+	ErrAmbiguousFramework  = errors.New("several frameworks detected; set Platform")
+	ErrNoPackageJSON       = errors.New("package.json not found")
+	ErrInvalidPackageJSON  = errors.New("package.json is not valid JSON")
+	ErrNotViteProject      = errors.New("not a Vite project")
+	ErrNoManifest          = errors.New("manifest not found")
+	ErrAssetsOutsideFS     = errors.New("assets path is outside FS")
+	ErrBufferedResponse    = errors.New("response is buffered for injection")
 )
@@ -0,0 +1,105 @@
+package vitgo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// etagCache holds the ETags hashed for files without a content
+// hash in their name, so each is only read once per modtime.
+type etagCache struct {
+	mu    sync.Mutex
+	etags map[string]cachedETag
+}
+
+// cachedETag is a file's ETag, for the modtime and size it had
+// when it was hashed.
+type cachedETag struct {
+	modTime time.Time
+	size    int64
+	etag    string
+}
+
+// setETag sets a strong ETag for a production file: the content
+// hash from its name if it has one, else a SHA-256 of its bytes.
+// http.ServeContent then answers a matching If-None-Match with
+// a 304 by itself, and uses the ETag for If-Range too. This is
+// the ETag of the identity encoding; encoded responses change it
+// with encodedETag.
+func (vg *VitGo) setETag(w http.ResponseWriter, fsys fs.FS, name string) {
+	// http.FileServer serves / from index.html
+	if name == "" {
+		name = "index.html"
+	}
+
+	if etag := vg.fileETag(fsys, name); etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+}
+
+// fileETag returns the ETag for name, or "" if it has none.
+func (vg *VitGo) fileETag(fsys fs.FS, name string) string {
+	if hash, ok := contentHash(name); ok {
+		return `"` + hash + `"`
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return ""
+	}
+
+	if vg.etagCache != nil {
+		vg.etagCache.mu.Lock()
+		cached, ok := vg.etagCache.etags[name]
+		vg.etagCache.mu.Unlock()
+
+		if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+			return cached.etag
+		}
+	}
+
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return ""
+	}
+
+	etag := `"` + hex.EncodeToString(sum.Sum(nil)[:16]) + `"`
+
+	if vg.etagCache != nil {
+		vg.etagCache.mu.Lock()
+		if vg.etagCache.etags == nil {
+			vg.etagCache.etags = map[string]cachedETag{}
+		}
+
+		vg.etagCache.etags[name] = cachedETag{modTime: info.ModTime(), size: info.Size(), etag: etag}
+		vg.etagCache.mu.Unlock()
+	}
+
+	return etag
+}
+
+// encodedETag marks the ETag already set as belonging to an
+// encoded variant, since its bytes differ from the plain file.
+// Each encoding gets its own strong validator, so a cache holding
+// the gzip bytes never revalidates them against the identity
+// ETag, or the other way round.
+func encodedETag(w http.ResponseWriter, encoding string) {
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		return
+	}
+
+	w.Header().Set("ETag", strings.TrimSuffix(etag, `"`)+"-"+encoding+`"`)
+}
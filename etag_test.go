@@ -0,0 +1,110 @@
+package vitgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// getWith requests target through handler with the given headers.
+func getWith(handler http.Handler, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestETagPerEncoding(t *testing.T) {
+	script := []byte(strings.Repeat("console.log('main');\n", 100))
+	page := []byte("<!doctype html>" + strings.Repeat("<p>app</p>", 200))
+
+	fsys := prodFS()
+	fsys["dist/assets/main-4f2a1b.js"] = &fstest.MapFile{Data: script}
+	fsys["dist/assets/main-4f2a1b.js.gz"] = &fstest.MapFile{Data: gzipped(t, script)}
+	fsys["dist/assets/main-4f2a1b.js.br"] = &fstest.MapFile{Data: []byte("not checked")}
+	fsys["dist/about.html"] = &fstest.MapFile{Data: page}
+
+	vg := newProdVitGo(t, fsys)
+	vg.CompressOnTheFly = true
+	handler := fileServer(t, vg)
+
+	tests := []struct {
+		name     string
+		target   string
+		encoding string
+	}{
+		{"precompressed", "/assets/main-4f2a1b.js", "gzip"},
+		{"precompressed brotli", "/assets/main-4f2a1b.js", "br"},
+		{"on the fly", "/about.html", "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := getWith(handler, tt.target, nil)
+			encoded := getWith(handler, tt.target, map[string]string{"Accept-Encoding": tt.encoding})
+
+			if got := encoded.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.encoding)
+			}
+
+			plainETag, encodedETag := plain.Header().Get("ETag"), encoded.Header().Get("ETag")
+			if plainETag == "" || encodedETag == "" || plainETag == encodedETag {
+				t.Fatalf("ETags identity %q and %s %q, want distinct", plainETag, tt.encoding, encodedETag)
+			}
+
+			// the identity ETag must not validate the encoded bytes
+			rec := getWith(handler, tt.target, map[string]string{"Accept-Encoding": tt.encoding, "If-None-Match": plainETag})
+			if rec.Code != http.StatusOK {
+				t.Errorf("encoded request with identity ETag = %d, want 200", rec.Code)
+			}
+
+			// nor the encoded ETag the identity bytes
+			rec = getWith(handler, tt.target, map[string]string{"If-None-Match": encodedETag})
+			if rec.Code != http.StatusOK {
+				t.Errorf("identity request with %s ETag = %d, want 200", tt.encoding, rec.Code)
+			}
+
+			rec = getWith(handler, tt.target, map[string]string{"Accept-Encoding": tt.encoding, "If-None-Match": encodedETag})
+			if rec.Code != http.StatusNotModified {
+				t.Errorf("encoded request with its ETag = %d, want 304", rec.Code)
+			}
+
+			rec = getWith(handler, tt.target, map[string]string{"If-None-Match": plainETag})
+			if rec.Code != http.StatusNotModified {
+				t.Errorf("identity request with its ETag = %d, want 304", rec.Code)
+			}
+		})
+	}
+}
+
+func TestETagCache(t *testing.T) {
+	fsys := prodFS()
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys["dist/about.html"] = &fstest.MapFile{Data: []byte("<p>one</p>"), ModTime: modTime}
+
+	handler := fileServer(t, newProdVitGo(t, fsys))
+
+	first := getWith(handler, "/about.html", nil).Header().Get("ETag")
+
+	// same size and modtime, so the cached ETag is kept
+	fsys["dist/about.html"] = &fstest.MapFile{Data: []byte("<p>two</p>"), ModTime: modTime}
+
+	if got := getWith(handler, "/about.html", nil).Header().Get("ETag"); got != first {
+		t.Errorf("ETag = %q, want cached %q", got, first)
+	}
+
+	// a new modtime means the file is hashed again
+	fsys["dist/about.html"] = &fstest.MapFile{Data: []byte("<p>two</p>"), ModTime: modTime.Add(time.Second)}
+
+	if got := getWith(handler, "/about.html", nil).Header().Get("ETag"); got == first {
+		t.Errorf("ETag = %q after a change, want a new one", got)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build vitgo_fiber
+
+package vitgo
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+)
+
+// FiberHandler returns FileServer as a Fiber handler. Build with
+// -tags vitgo_fiber to include it.
+func (vg *VitGo) FiberHandler() (fiber.Handler, error) {
+	handler, err := vg.FileServer()
+	if err != nil {
+		return nil, err
+	}
+
+	return adaptor.HTTPHandler(handler), nil
+}
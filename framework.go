@@ -0,0 +1,51 @@
+package vitgo
+
+// Framework returns the framework detected in package.json, such
+// as "vue" or "react", or "" if detection has not run.
+func (vc *ViteConfig) Framework() string {
+	if vc.DevDefaults == nil {
+		return ""
+	}
+
+	return vc.DevDefaults.PackageType
+}
+
+// FrameworkVersion returns the version of the detected framework
+// as declared in package.json, without range operators, or "" if
+// detection has not run or found no framework.
+func (vc *ViteConfig) FrameworkVersion() string {
+	if vc.DevDefaults == nil {
+		return ""
+	}
+
+	defaults := vc.DevDefaults
+
+	switch defaults.PackageType {
+	case "vue":
+		return defaults.VueVersion
+	case "react":
+		return defaults.ReactVersion
+	case "preact":
+		return defaults.PreactVersion
+	case "svelte":
+		return defaults.SvelteVersion
+	case "lit":
+		return defaults.LitVersion
+	case "solid":
+		return defaults.SolidVersion
+	case "astro":
+		return defaults.AstroVersion
+	case "qwik":
+		return defaults.QwikVersion
+	case "angular":
+		return defaults.AngularVersion
+	}
+
+	return ""
+}
+
+// UsesTypeScript reports whether package.json depends on
+// typescript. It is false if detection has not run.
+func (vc *ViteConfig) UsesTypeScript() bool {
+	return vc.DevDefaults != nil && vc.DevDefaults.HasTypeScript
+}
@@ -0,0 +1,18 @@
+//go:build vitgo_gin
+
+package vitgo
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// GinHandler returns FileServer as a Gin handler. Build with
+// -tags vitgo_gin to include it.
+func (vg *VitGo) GinHandler() (gin.HandlerFunc, error) {
+	handler, err := vg.FileServer()
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.WrapH(handler), nil
+}
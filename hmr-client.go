@@ -0,0 +1,70 @@
+package vitgo
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// viteClientPath is the module the browser loads Vite's client
+// from, under the dev server's base.
+const viteClientPath = "/@vite/client"
+
+// socketProtocolLine is where Vite's client picks the HMR
+// websocket's scheme, ws or wss, by default from the page's.
+var socketProtocolLine = regexp.MustCompile(`(?m)^const socketProtocol = .*;$`)
+
+// hmrProtocol returns the scheme HMRSecure forces on the HMR
+// websocket, or "" when it is not set and Vite's client should
+// keep its own.
+func (vg *VitGo) hmrProtocol() string {
+	if vg.config == nil || vg.config.HMRSecure == nil || vg.HMRWebSocketURL == "" {
+		return ""
+	}
+
+	hmr, err := url.Parse(vg.HMRWebSocketURL)
+	if err != nil {
+		return ""
+	}
+
+	return hmr.Scheme
+}
+
+// isViteClientRequest reports whether r asks for Vite's client.
+func isViteClientRequest(r *http.Request) bool {
+	return strings.HasSuffix(r.URL.Path, viteClientPath)
+}
+
+// rewriteViteClient is part of the dev proxy's ModifyResponse:
+// when HMRSecure is set, it makes the proxied Vite client open
+// its HMR websocket with HMRWebSocketURL's scheme, since behind
+// a proxy terminating TLS Vite can't tell that wss is needed.
+// The host and port are left to Vite's server.hmr settings.
+func (vg *VitGo) rewriteViteClient(resp *http.Response) error {
+	protocol := vg.hmrProtocol()
+	if protocol == "" || resp.StatusCode != http.StatusOK || resp.Request == nil ||
+		!isViteClientRequest(resp.Request) || resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	body = socketProtocolLine.ReplaceAllLiteral(body, []byte(`const socketProtocol = "`+protocol+`";`))
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	// Vite's ETag is for the bytes before the rewrite
+	resp.Header.Del("ETag")
+
+	return nil
+}
@@ -0,0 +1,241 @@
+package vitgo
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DEFAULT_HMR_PING_INTERVAL is how often DevProxyHandler pings
+// the browser on a proxied HMR websocket when HMRPingInterval
+// is 0.
+const DEFAULT_HMR_PING_INTERVAL = 30 * time.Second
+
+// pingFrame is an unmasked websocket ping with no payload, as a
+// server sends it.
+var pingFrame = []byte{0x89, 0x00}
+
+// hmrPingInterval returns the ping interval, or 0 when pings
+// are turned off.
+func (vg *VitGo) hmrPingInterval() time.Duration {
+	switch {
+	case vg.HMRPingInterval < 0:
+		return 0
+	case vg.HMRPingInterval == 0:
+		return DEFAULT_HMR_PING_INTERVAL
+	}
+
+	return vg.HMRPingInterval
+}
+
+// pingWebSocket is the dev proxy's ModifyResponse: on a
+// websocket upgrade it wraps the dev server's side of the
+// connection so the browser is pinged while it is idle.
+func (vg *VitGo) pingWebSocket(resp *http.Response) error {
+	interval := vg.hmrPingInterval()
+	if interval == 0 || resp.StatusCode != http.StatusSwitchingProtocols ||
+		!strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") {
+		return nil
+	}
+
+	upstream, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		return nil
+	}
+
+	resp.Body = newPingingConn(upstream, interval)
+
+	return nil
+}
+
+// pingingConn sits between the dev server and the proxy's copy
+// to the browser. What it reads from the dev server is passed
+// on unchanged; when a tick comes while the stream is between
+// frames, a ping frame is handed out instead. Pings are never
+// inserted mid-frame, and the browser's pongs simply go up to
+// Vite, which ignores them.
+type pingingConn struct {
+	upstream io.ReadWriteCloser
+	ticker   *time.Ticker
+
+	chunks  chan []byte
+	done    chan struct{}
+	readErr error
+	once    sync.Once
+
+	pending []byte
+	frames  frameScanner
+}
+
+// newPingingConn starts pumping upstream and pinging every
+// interval, until either side closes.
+func newPingingConn(upstream io.ReadWriteCloser, interval time.Duration) *pingingConn {
+	c := &pingingConn{
+		upstream: upstream,
+		ticker:   time.NewTicker(interval),
+		chunks:   make(chan []byte),
+		done:     make(chan struct{}),
+	}
+
+	go c.pump()
+
+	return c
+}
+
+// pump reads from the dev server until it fails or c is closed.
+func (c *pingingConn) pump() {
+	defer close(c.chunks)
+
+	for {
+		buf := make([]byte, 32*1024)
+
+		n, err := c.upstream.Read(buf)
+		if n > 0 {
+			select {
+			case c.chunks <- buf[:n]:
+			case <-c.done:
+				return
+			}
+		}
+
+		if err != nil {
+			// read by Read only after chunks is closed
+			c.readErr = err
+			return
+		}
+	}
+}
+
+// Read implements io.Reader for pingingConn.
+func (c *pingingConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		select {
+		case chunk, ok := <-c.chunks:
+			if !ok {
+				return 0, c.readErr
+			}
+
+			c.frames.feed(chunk)
+			c.pending = chunk
+
+		case <-c.ticker.C:
+			// data queued or mid-frame means the
+			// connection is not idle anyway
+			if c.frames.atBoundary() {
+				c.pending = pingFrame
+			}
+
+		case <-c.done:
+			return 0, io.EOF
+		}
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+
+	return n, nil
+}
+
+// Write implements io.Writer for pingingConn.
+func (c *pingingConn) Write(p []byte) (int, error) {
+	return c.upstream.Write(p)
+}
+
+// CloseWrite passes the browser's half-close on to the dev
+// server when its connection supports it, as the proxy would
+// without the ping layer.
+func (c *pingingConn) CloseWrite() error {
+	if wc, ok := c.upstream.(interface{ CloseWrite() error }); ok {
+		return wc.CloseWrite()
+	}
+
+	return errors.ErrUnsupported
+}
+
+// Close implements io.Closer for pingingConn.
+func (c *pingingConn) Close() error {
+	c.once.Do(func() {
+		c.ticker.Stop()
+		close(c.done)
+	})
+
+	return c.upstream.Close()
+}
+
+// frameScanner follows websocket frame boundaries in a byte
+// stream, without keeping payloads.
+type frameScanner struct {
+	header    []byte
+	remaining uint64
+}
+
+// feed advances the scanner over b.
+func (s *frameScanner) feed(b []byte) {
+	for len(b) > 0 {
+		if s.remaining > 0 {
+			n := s.remaining
+			if n > uint64(len(b)) {
+				n = uint64(len(b))
+			}
+
+			s.remaining -= n
+			b = b[n:]
+
+			continue
+		}
+
+		s.header = append(s.header, b[0])
+		b = b[1:]
+
+		if length, ok := frameLength(s.header); ok {
+			s.remaining = length
+			s.header = s.header[:0]
+		}
+	}
+}
+
+// atBoundary reports whether the stream so far ends on a
+// complete frame.
+func (s *frameScanner) atBoundary() bool {
+	return len(s.header) == 0 && s.remaining == 0
+}
+
+// frameLength returns the payload length once header holds a
+// whole frame header.
+func frameLength(header []byte) (uint64, bool) {
+	if len(header) < 2 {
+		return 0, false
+	}
+
+	size := 2
+	length := uint64(header[1] & 0x7f)
+
+	switch length {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+
+	if header[1]&0x80 != 0 {
+		// masked
+		size += 4
+	}
+
+	if len(header) < size {
+		return 0, false
+	}
+
+	switch length {
+	case 126:
+		length = uint64(binary.BigEndian.Uint16(header[2:4]))
+	case 127:
+		length = binary.BigEndian.Uint64(header[2:10])
+	}
+
+	return length, true
+}
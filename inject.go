@@ -0,0 +1,191 @@
+package vitgo
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// headClose is where InjectMiddleware puts the tags.
+var headClose = []byte("</head>")
+
+// InjectMiddleware adds the tags RenderTags renders for the main
+// entry to HTML pages next produces, just before </head>, for
+// apps with server-rendered HTML of their own. Other responses
+// pass through untouched, as do pages that already load Vite's
+// client or the entry script, so tags are never doubled.
+// Flush and Hijack reach w for responses that are not buffered.
+// Requests to a host other than CanonicalHost are redirected.
+func (vg *VitGo) InjectMiddleware(next http.Handler) http.Handler {
+	return vg.canonicalHost(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		iw := &injectWriter{ResponseWriter: w, head: r.Method == http.MethodHead}
+		next.ServeHTTP(iw, r)
+		iw.finish(vg)
+	}))
+}
+
+// injectWriter buffers an HTML response so tags can be added to
+// it, and passes anything else straight through.
+type injectWriter struct {
+	http.ResponseWriter
+	head    bool
+	status  int
+	decided bool
+	inject  bool
+	buf     bytes.Buffer
+}
+
+// decide checks, once headers are final, whether to buffer.
+func (iw *injectWriter) decide(first []byte) {
+	iw.decided = true
+
+	header := iw.Header()
+	if header.Get("Content-Type") == "" && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Type", http.DetectContentType(first))
+	}
+
+	iw.inject = iw.status == http.StatusOK &&
+		header.Get("Content-Encoding") == "" &&
+		strings.HasPrefix(header.Get("Content-Type"), "text/html")
+
+	if !iw.inject {
+		iw.ResponseWriter.WriteHeader(iw.status)
+	}
+}
+
+// WriteHeader implements http.ResponseWriter for injectWriter.
+func (iw *injectWriter) WriteHeader(status int) {
+	if iw.decided || iw.status != 0 {
+		return
+	}
+
+	iw.status = status
+
+	if status != http.StatusOK {
+		iw.decide(nil)
+	}
+}
+
+// Write implements http.ResponseWriter for injectWriter.
+func (iw *injectWriter) Write(b []byte) (int, error) {
+	if !iw.decided {
+		if iw.status == 0 {
+			iw.status = http.StatusOK
+		}
+
+		iw.decide(b)
+	}
+
+	if iw.inject {
+		return iw.buf.Write(b)
+	}
+
+	return iw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped ResponseWriter, for
+// http.ResponseController.
+func (iw *injectWriter) Unwrap() http.ResponseWriter {
+	return iw.ResponseWriter
+}
+
+// Flush implements http.Flusher for injectWriter. Headers are
+// final once flushed; a page being buffered for injection is
+// only sent by finish, so flushing it does nothing.
+func (iw *injectWriter) Flush() {
+	if !iw.decided {
+		if iw.status == 0 {
+			iw.status = http.StatusOK
+		}
+
+		iw.decide(nil)
+	}
+
+	if iw.inject {
+		return
+	}
+
+	http.NewResponseController(iw.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker for injectWriter, as for
+// websocket upgrades. A page already being buffered can't be
+// hijacked.
+func (iw *injectWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if iw.inject {
+		return nil, nil, ErrBufferedResponse
+	}
+
+	conn, rw, err := http.NewResponseController(iw.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the connection is the handler's now, so finish leaves it
+	iw.decided = true
+
+	return conn, rw, nil
+}
+
+// finish writes out a buffered page, with the tags added. When
+// they are, next's ETag and Last-Modified no longer describe the
+// page and are dropped.
+func (iw *injectWriter) finish(vg *VitGo) {
+	if !iw.decided {
+		if iw.status != 0 {
+			iw.ResponseWriter.WriteHeader(iw.status)
+		}
+
+		return
+	}
+
+	if !iw.inject {
+		return
+	}
+
+	page := iw.buf.Bytes()
+
+	if at := bytes.Index(page, headClose); at >= 0 && !vg.hasTags(page) {
+		tags, err := vg.RenderTags()
+		if err != nil {
+			vg.logger().Error("could not render tags to inject", "error", err)
+		} else {
+			injected := make([]byte, 0, len(page)+len(tags))
+			injected = append(injected, page[:at]...)
+			injected = append(injected, tags...)
+			injected = append(injected, page[at:]...)
+			page = injected
+
+			// the validators were for the page before the tags
+			iw.Header().Del("ETag")
+			iw.Header().Del("Last-Modified")
+		}
+	}
+
+	// a HEAD has no body to measure, so next's length stands
+	if !iw.head {
+		iw.Header().Set("Content-Length", strconv.Itoa(len(page)))
+	}
+
+	iw.ResponseWriter.WriteHeader(iw.status)
+
+	if _, err := iw.ResponseWriter.Write(page); err != nil {
+		vg.logger().Error("could not write page", "error", err)
+	}
+}
+
+// hasTags reports whether page already loads Vite's client or
+// the entry script.
+func (vg *VitGo) hasTags(page []byte) bool {
+	if bytes.Contains(page, []byte("@vite/client")) {
+		return true
+	}
+
+	vg = vg.reloaded()
+
+	return vg.Environment == "production" && vg.MainModule != "" &&
+		bytes.Contains(page, []byte(vg.AssetURL(vg.MainModule)))
+}
@@ -0,0 +1,158 @@
+package vitgo
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestInjectMiddlewareFlush(t *testing.T) {
+	vg := newProdVitGo(t, prodFS())
+
+	tests := []struct {
+		name        string
+		contentType string
+		flushed     bool
+		injected    bool
+	}{
+		{"stream", "text/event-stream", true, false},
+		{"page", "text/html; charset=utf-8", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var flushed bool
+
+			handler := vg.InjectMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				io.WriteString(w, "<html><head></head>")
+
+				if err := http.NewResponseController(w).Flush(); err != nil {
+					t.Errorf("Flush: %v", err)
+				}
+
+				flushed = w.(interface{ Unwrap() http.ResponseWriter }).Unwrap().(*httptest.ResponseRecorder).Flushed
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if flushed != tt.flushed {
+				t.Errorf("flushed = %v, want %v", flushed, tt.flushed)
+			}
+
+			if got := strings.Contains(rec.Body.String(), "assets/main-4f2a1b.js"); got != tt.injected {
+				t.Errorf("body = %q, injected = %v, want %v", rec.Body.String(), got, tt.injected)
+			}
+		})
+	}
+}
+
+func TestInjectMiddlewareHijack(t *testing.T) {
+	vg := newProdVitGo(t, prodFS())
+
+	server := httptest.NewServer(vg.InjectMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page" {
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, "<html><head></head>")
+
+			if _, _, err := http.NewResponseController(w).Hijack(); !errors.Is(err, ErrBufferedResponse) {
+				t.Errorf("Hijack of a buffered page: err = %v, want ErrBufferedResponse", err)
+			}
+
+			return
+		}
+
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("Hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+	})))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	io.WriteString(conn, "GET /socket HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("ReadResponse: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+
+	page, err := http.Get(server.URL + "/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer page.Body.Close()
+
+	if body, _ := io.ReadAll(page.Body); !strings.Contains(string(body), "assets/main-4f2a1b.js") {
+		t.Errorf("page = %q, want the tags injected", body)
+	}
+}
+
+func TestInjectMiddlewareHeaders(t *testing.T) {
+	vg := newProdVitGo(t, prodFS())
+
+	tests := []struct {
+		name   string
+		method string
+		page   string
+		etag   string
+		length string
+	}{
+		{"injected", http.MethodGet, "<html><head></head>", "", ""},
+		{"has tags", http.MethodGet, `<html><head><script src="/assets/main-4f2a1b.js"></script></head>`, `"page"`, ""},
+		{"head", http.MethodHead, "", `"page"`, "1234"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := vg.InjectMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Header().Set("Content-Length", "1234")
+				w.Header().Set("ETag", `"page"`)
+				w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+				io.WriteString(w, tt.page)
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, "/", nil))
+
+			if got := rec.Header().Get("ETag"); got != tt.etag {
+				t.Errorf("ETag = %q, want %q", got, tt.etag)
+			}
+
+			if got := rec.Header().Get("Last-Modified"); (got != "") != (tt.etag != "") {
+				t.Errorf("Last-Modified = %q, want it kept only with the ETag", got)
+			}
+
+			length := tt.length
+			if length == "" {
+				length = strconv.Itoa(rec.Body.Len())
+			}
+
+			if got := rec.Header().Get("Content-Length"); got != length {
+				t.Errorf("Content-Length = %q, want %q", got, length)
+			}
+		})
+	}
+}
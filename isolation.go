@@ -0,0 +1,48 @@
+package vitgo
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// setIsolationHeaders sets the cross-origin isolation headers
+// for the requested path (see IsolateCrossOrigin).
+func (vg *VitGo) setIsolationHeaders(w http.ResponseWriter, requestPath string) {
+	if !vg.IsolateCrossOrigin {
+		return
+	}
+
+	header := w.Header()
+	header.Set("Cross-Origin-Resource-Policy", "same-origin")
+
+	switch ext := path.Ext(requestPath); {
+	case ext == "" || strings.HasSuffix(requestPath, "/") || ext == ".html":
+		// documents
+		header.Set("Cross-Origin-Opener-Policy", "same-origin")
+		header.Set("Cross-Origin-Embedder-Policy", "require-corp")
+
+	case ext == ".js" || ext == ".mjs":
+		// worker scripts must carry COEP to be isolated too
+		header.Set("Cross-Origin-Embedder-Policy", "require-corp")
+	}
+}
+
+// CrossOriginIsolation wraps the handler serving your HTML shell
+// so it carries the same isolation headers as FileServer when
+// IsolateCrossOrigin is set. Like FileServer, it redirects to
+// CanonicalHost.
+func (vg *VitGo) CrossOriginIsolation(next http.Handler) http.Handler {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if vg.IsolateCrossOrigin {
+			header := w.Header()
+			header.Set("Cross-Origin-Opener-Policy", "same-origin")
+			header.Set("Cross-Origin-Embedder-Policy", "require-corp")
+			header.Set("Cross-Origin-Resource-Policy", "same-origin")
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return vg.canonicalHost(http.HandlerFunc(handler))
+}
@@ -0,0 +1,60 @@
+package vitgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCrossOriginIsolationHeaders(t *testing.T) {
+	vg := newProdVitGo(t, prodFS())
+	vg.IsolateCrossOrigin = true
+
+	mux := http.NewServeMux()
+	mux.Handle("/", fileServer(t, vg))
+	mux.Handle("/shell", vg.CrossOriginIsolation(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<!doctype html>"))
+	})))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	const (
+		coop = "Cross-Origin-Opener-Policy"
+		coep = "Cross-Origin-Embedder-Policy"
+		corp = "Cross-Origin-Resource-Policy"
+	)
+
+	tests := []struct {
+		name   string
+		target string
+		want   map[string]string
+	}{
+		{"shell", "/shell", map[string]string{coop: "same-origin", coep: "require-corp", corp: "same-origin"}},
+		{"document", "/index.html", map[string]string{coop: "same-origin", coep: "require-corp", corp: "same-origin"}},
+		{"root document", "/", map[string]string{coop: "same-origin", coep: "require-corp", corp: "same-origin"}},
+		{"worker script", "/assets/main-4f2a1b.js", map[string]string{coop: "", coep: "require-corp", corp: "same-origin"}},
+		{"stylesheet", "/assets/main-9c8d7e.css", map[string]string{coop: "", coep: "", corp: "same-origin"}},
+		{"image", "/assets/images/logo.svg", map[string]string{coop: "", coep: "", corp: "same-origin"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET %s = %d, want 200", tt.target, resp.StatusCode)
+			}
+
+			for name, want := range tt.want {
+				if got := resp.Header.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package vitgo
+
+import (
+	"encoding/json"
+	"io/fs"
+)
+
+// ReadJSONConfig reads a JSON config file (such as vitgo.json)
+// from the JS project into v. When LenientJSON is set, // and
+// /* */ comments and trailing commas are tolerated. package.json
+// is always parsed strictly and does not go through here.
+func (vc *ViteConfig) ReadJSONConfig(name string, v any) error {
+	projectFS, err := correctFS(vc.FS, vc.FSRoot, vc.JSProjectPath)
+	if err != nil {
+		return err
+	}
+
+	buf, err := fs.ReadFile(projectFS, name)
+	if err != nil {
+		return err
+	}
+
+	if vc.LenientJSON {
+		buf = stripJSONC(buf)
+	}
+
+	return json.Unmarshal(buf, v)
+}
+
+// stripJSONC removes comments and trailing commas from JSONC
+// input, leaving the contents of strings untouched.
+func stripJSONC(in []byte) []byte {
+	out := make([]byte, 0, len(in))
+
+	for i := 0; i < len(in); i++ {
+		c := in[i]
+
+		switch {
+		case c == '"':
+			// copy the string, minding escapes
+			start := i
+			for i++; i < len(in) && in[i] != '"'; i++ {
+				if in[i] == '\\' {
+					i++
+				}
+			}
+
+			end := i + 1
+			if end > len(in) {
+				end = len(in)
+			}
+
+			out = append(out, in[start:end]...)
+
+		case c == '/' && i+1 < len(in) && in[i+1] == '/':
+			for i < len(in) && in[i] != '\n' {
+				i++
+			}
+
+			if i < len(in) {
+				out = append(out, '\n')
+			}
+
+		case c == '/' && i+1 < len(in) && in[i+1] == '*':
+			for i += 2; i+1 < len(in) && !(in[i] == '*' && in[i+1] == '/'); i++ {
+			}
+
+			i++
+
+		case c == '}' || c == ']':
+			// drop a trailing comma before the closer
+			j := len(out) - 1
+			for j >= 0 && isJSONSpace(out[j]) {
+				j--
+			}
+
+			if j >= 0 && out[j] == ',' {
+				out = append(out[:j], out[j+1:]...)
+			}
+
+			out = append(out, c)
+
+		default:
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
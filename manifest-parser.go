@@ -1,153 +1,139 @@
 package vitgo
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"reflect"
-	"strconv"
 )
 
-type manifestNode struct {
-	key      string
-	nodeType reflect.Kind
-	value    reflect.Value
-	children []*manifestNode
-}
+// parseManifest unmarshals the manifest's contents.
+func parseManifest(contents []byte) (Manifest, error) {
+	manifest := Manifest{}
+	if err := json.Unmarshal(contents, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrManifestBadlyFormed, err)
+	}
 
-type manifestTarget struct {
-	File    string   `json:"file"`
-	Source  string   `json:"src"`
-	IsEntry bool     `json:"isEntry"`
-	Imports []string `json:"imports"`
-	CSS     []string `json:"css"`
-	Nodes   []*manifestNode
+	return manifest, nil
 }
 
-func (n *manifestNode) subKey(key string) *manifestNode {
-	if len(n.children) == 0 {
+// manifestKeys returns the manifest's source paths in the order
+// Vite wrote them, which follows the build's inputs.
+func manifestKeys(contents []byte) []string {
+	dec := json.NewDecoder(bytes.NewReader(contents))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
 		return nil
 	}
 
-	for _, leaf := range n.children {
-		if leaf.key == key {
-			return leaf
-		}
-	}
+	var keys []string
 
-	return nil
-}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
 
-// forked from: https://yourbasic.org/golang/json-example
-func (m *manifestTarget) parseWithoutReflection(jsonData []byte) (*VitGo, error) {
-	var v interface{}
-	json.Unmarshal(jsonData, &v)
+		key, _ := tok.(string)
+		keys = append(keys, key)
 
-	topNode := manifestNode{
-		key: "top",
+		var skipped json.RawMessage
+		if err := dec.Decode(&skipped); err != nil {
+			break
+		}
 	}
 
-	m.Nodes = append(m.Nodes, &topNode)
-	m.siftCollections(&topNode, "", "", v)
-
-	// Get entry point
-	entry := (*manifestNode)(nil)
-	vgo := &VitGo{}
+	return keys
+}
 
-	for _, leaf := range topNode.children {
-		if leaf.subKey("isEntry") != nil {
-			entry = leaf
-			vgo.MainModule = leaf.subKey("file").value.String()
-			break
+// mainEntry returns the assets of the first of names that is an
+// entry.
+func (m Manifest) mainEntry(names []string) (*VitGo, error) {
+	for _, name := range names {
+		if m[name].IsEntry {
+			return m.entryAssets(name)
 		}
 	}
 
-	if entry == nil {
-		return nil, ErrNoEntryPoint
-	}
+	return nil, ErrNoEntryPoint
+}
 
-	imports := entry.subKey("imports")
-	if imports == nil || len(imports.children) == 0 {
-		// return nil, errors.New("expected code to have js dependencies")
-		// turns out this will become optional as of Vite 2.9, so:
-	} else {
-		for _, child := range imports.children {
-			// these have a level of indirection for some reason
-			deref := topNode.subKey(child.value.String())
-			if deref == nil {
-				return nil, ErrNoInputFile
-			}
+// inputAssets resolves each named input (name -> source path)
+// to the assets of its manifest entry.
+func (m Manifest) inputAssets(inputs map[string]string) (map[string]*VitGo, error) {
+	entries := map[string]*VitGo{}
 
-			item := deref.subKey("file")
-			if item == nil {
-				return nil, ErrManifestBadlyFormed
-			}
+	for name, src := range inputs {
+		if entry, ok := m[src]; !ok || !entry.IsEntry {
+			return nil, fmt.Errorf("%w: input %q (%s)", ErrNoEntryPoint, name, src)
+		}
 
-			vgo.Imports = append(vgo.Imports, item.value.String())
+		vgo, err := m.entryAssets(src)
+		if err != nil {
+			return nil, err
 		}
+
+		entries[name] = vgo
 	}
 
-	css := entry.subKey("css")
-	if css == nil || len(css.children) == 0 {
-		// not an error, since CSS is optional
-		return vgo, nil
+	return entries, nil
+}
+
+// entryAssets collects the file of an entry, the chunks it
+// imports, direct ones first and then transitive ones (see
+// ImportedChunks), and its CSS along with theirs, each once.
+func (m Manifest) entryAssets(name string) (*VitGo, error) {
+	entry := m[name]
+	if entry.File == "" {
+		return nil, ErrManifestBadlyFormed
 	}
 
-	for _, child := range css.children {
-		vgo.CSSModule = append(vgo.CSSModule, child.value.String())
+	// imports are optional as of Vite 2.9, and are keys of
+	// other chunks rather than files
+	for _, key := range entry.Imports {
+		if _, ok := m[key]; !ok {
+			return nil, ErrNoInputFile
+		}
 	}
 
-	return vgo, nil
-}
+	vgo := &VitGo{MainModule: entry.File}
 
-func (m *manifestTarget) siftCollections(leaf *manifestNode, indent, key string, v interface{}) {
-	data, ok := v.(map[string]interface{})
+	// not an error, since CSS is optional
+	vgo.CSSModule = append(vgo.CSSModule, entry.CSS...)
 
-	if ok {
-		leaf.nodeType = reflect.Map
+	seenCSS := map[string]bool{}
+	for _, css := range entry.CSS {
+		seenCSS[css] = true
+	}
 
-		for k, v := range data {
-			child := &manifestNode{
-				key: k,
-			}
-			leaf.children = append(leaf.children, child)
-			m.processInterface(child, indent, k, v)
+	for _, chunk := range m.ImportedChunks(name) {
+		if chunk.File == "" {
+			return nil, ErrManifestBadlyFormed
 		}
-	} else if arrayData, ok := v.([]interface{}); ok {
-		leaf.nodeType = reflect.Slice
 
-		for i, v := range arrayData {
-			child := &manifestNode{}
-			leaf.children = append(leaf.children, child)
-			m.processInterface(child, indent, strconv.Itoa(i), v)
+		vgo.Imports = append(vgo.Imports, chunk.File)
+
+		for _, css := range chunk.CSS {
+			if !seenCSS[css] {
+				seenCSS[css] = true
+				vgo.CSSModule = append(vgo.CSSModule, css)
+			}
 		}
-	} else {
-		m.processInterface(leaf, indent, key, v)
 	}
-}
-
-// call this for recurisve structures.
-func (m *manifestTarget) processInterface(leaf *manifestNode, indent, k string, v interface{}) {
-	// Cover types we know we get in JSON
-	switch v := v.(type) {
-	case string:
-		leaf.nodeType = reflect.String
-		leaf.value = reflect.ValueOf(v)
-
-	case float64:
-		leaf.nodeType = reflect.Float64
-		leaf.value = reflect.ValueOf(v)
 
-	case bool:
-		leaf.nodeType = reflect.Bool
-		leaf.value = reflect.ValueOf(v)
+	return vgo, nil
+}
 
-	case []interface{}:
-		m.siftCollections(leaf, indent+"    ", k, v)
+// manifestIntegrity maps each chunk's file to the integrity value
+// a plugin recorded for it. Most manifests have none.
+func manifestIntegrity(m Manifest) map[string]string {
+	integrity := map[string]string{}
 
-	case map[string]interface{}:
-		m.siftCollections(leaf, indent+"    ", k, v)
+	for _, entry := range m {
+		if entry.File == "" || entry.Integrity == "" {
+			continue
+		}
 
-	default:
-		fmt.Printf("%s %s ?? %T (unknown)", indent, k, v)
+		integrity[entry.File] = entry.Integrity
 	}
+
+	return integrity
 }
@@ -0,0 +1,137 @@
+package vitgo
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+)
+
+// MANIFEST_POLL_INTERVAL is how often WatchManifest checks the
+// manifest's modtime.
+const MANIFEST_POLL_INTERVAL = time.Second
+
+// manifestWatch holds the latest manifest WatchManifest loaded.
+// It lives behind a pointer so every copy of a VitGo sees it.
+type manifestWatch struct {
+	mu     sync.Mutex
+	loaded *VitGo
+}
+
+// WatchManifest reloads the manifest, in production, whenever
+// its modtime changes, so a server whose build is redone in
+// place (say, on staging) picks up the new hashed files without
+// a restart. It polls until ctx is done, then returns nil; run
+// it in its own goroutine. A manifest that fails to parse, as
+// one caught mid-write, is retried on the next poll, and the
+// previous one is kept meanwhile. For an embed.FS, which cannot
+// change, it returns at once.
+func (vg *VitGo) WatchManifest(ctx context.Context) error {
+	if vg.Environment != "production" || vg.manifestWatch == nil || vg.config == nil || vg.manifestFile == "" {
+		return nil
+	}
+
+	switch vg.config.FS.(type) {
+	case embed.FS, *embed.FS:
+		return nil
+	}
+
+	manifestPath := path.Join(vg.AssetPath, vg.manifestFile)
+
+	info, err := fs.Stat(vg.DistFS, manifestPath)
+	if err != nil {
+		return err
+	}
+
+	modTime := info.ModTime()
+
+	ticker := time.NewTicker(MANIFEST_POLL_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-ticker.C:
+		}
+
+		info, err := fs.Stat(vg.DistFS, manifestPath)
+		if err != nil || info.ModTime().Equal(modTime) {
+			continue
+		}
+
+		if err := vg.reloadManifest(manifestPath); err != nil {
+			vg.logger().Warn("vitgo: could not reload manifest", "path", manifestPath, "error", err)
+			continue
+		}
+
+		modTime = info.ModTime()
+	}
+}
+
+// reloadManifest parses the manifest again and drops what was
+// cached from the previous one.
+func (vg *VitGo) reloadManifest(manifestPath string) error {
+	contents, err := fs.ReadFile(vg.DistFS, manifestPath)
+	if err != nil {
+		return err
+	}
+
+	loaded, err := vg.config.loadManifest(contents)
+	if err != nil {
+		return err
+	}
+
+	vg.manifestWatch.mu.Lock()
+	vg.manifestWatch.loaded = loaded
+	vg.manifestWatch.mu.Unlock()
+
+	if vg.manifestCache != nil {
+		vg.manifestCache.mu.Lock()
+		vg.manifestCache.manifest = nil
+		vg.manifestCache.mu.Unlock()
+	}
+
+	if vg.sriCache != nil {
+		vg.sriCache.mu.Lock()
+		vg.sriCache.hashes = nil
+		vg.sriCache.mu.Unlock()
+	}
+
+	vg.logger().Info("vitgo: manifest reloaded", "path", manifestPath)
+
+	return nil
+}
+
+// reloaded returns vg with the manifest WatchManifest last
+// loaded, if any. Like watched, it leaves vg itself alone. The
+// copy no longer follows the watch, so its assets stay put once
+// picked, even while it is narrowed down to one entry.
+func (vg *VitGo) reloaded() *VitGo {
+	if vg.manifestWatch == nil {
+		return vg
+	}
+
+	vg.manifestWatch.mu.Lock()
+	loaded := vg.manifestWatch.loaded
+	vg.manifestWatch.mu.Unlock()
+
+	if loaded == nil {
+		return vg
+	}
+
+	updated := *vg
+	updated.MainModule = loaded.MainModule
+	updated.Imports = loaded.Imports
+	updated.CSSModule = loaded.CSSModule
+	updated.manifest = loaded.manifest
+	updated.caseIndex = loaded.caseIndex
+	updated.integrity = loaded.integrity
+	updated.inputs = loaded.inputs
+	updated.manifestWatch = nil
+
+	return &updated
+}
@@ -0,0 +1,149 @@
+package vitgo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ManifestEntry is one chunk of Vite's build manifest.
+type ManifestEntry struct {
+	File           string   `json:"file"`
+	Src            string   `json:"src,omitempty"`
+	IsEntry        bool     `json:"isEntry,omitempty"`
+	CSS            []string `json:"css,omitempty"`
+	Assets         []string `json:"assets,omitempty"`
+	Imports        []string `json:"imports,omitempty"`
+	DynamicImports []string `json:"dynamicImports,omitempty"`
+	Integrity      string   `json:"integrity,omitempty"`
+}
+
+// Manifest is Vite's build manifest, keyed by source path.
+type Manifest map[string]ManifestEntry
+
+// manifestCache holds the parsed manifest. It lives behind a
+// pointer so VitGo values can be copied.
+type manifestCache struct {
+	mu       sync.Mutex
+	manifest Manifest
+}
+
+// ParseManifest returns the build manifest: the one NewVitGo
+// loaded, or the one WatchManifest last reloaded. Without one,
+// it reads it from DistFS and AssetPath, trying
+// .vite/manifest.json and then manifest.json, and caches the
+// result.
+func (vg *VitGo) ParseManifest() (Manifest, error) {
+	if loaded := vg.reloaded().manifest; loaded != nil {
+		return loaded, nil
+	}
+
+	if vg.manifestCache == nil {
+		return vg.readManifest()
+	}
+
+	vg.manifestCache.mu.Lock()
+	defer vg.manifestCache.mu.Unlock()
+
+	if vg.manifestCache.manifest != nil {
+		return vg.manifestCache.manifest, nil
+	}
+
+	manifest, err := vg.readManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	vg.manifestCache.manifest = manifest
+
+	return manifest, nil
+}
+
+// readManifest reads the manifest without caching.
+func (vg *VitGo) readManifest() (Manifest, error) {
+	if vg.DistFS == nil {
+		return nil, fmt.Errorf("could not read manifest: %w", ErrManifestDNF)
+	}
+
+	candidates := DefaultManifestFiles
+	if vg.manifestFile != "" {
+		candidates = []string{vg.manifestFile}
+	}
+
+	var tried []string
+
+	for _, candidate := range candidates {
+		manifestPath := path.Join(vg.AssetPath, candidate)
+		tried = append(tried, manifestPath)
+
+		contents, err := fs.ReadFile(vg.DistFS, manifestPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		manifest, err := parseManifest(contents)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", manifestPath, err)
+		}
+
+		return manifest, nil
+	}
+
+	return nil, fmt.Errorf("%w (tried %s): %w", ErrNoManifest, strings.Join(tried, ", "), fs.ErrNotExist)
+}
+
+// ResolveEntry returns the manifest entry for a source path,
+// such as src/main.tsx.
+func (vg *VitGo) ResolveEntry(name string) (*ManifestEntry, error) {
+	manifest, err := vg.ParseManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := manifest[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownEntry, name)
+	}
+
+	return &entry, nil
+}
+
+// ImportedChunks returns the chunks name imports, direct imports
+// first and then transitive ones. Each chunk appears once, even
+// when shared or imported circularly.
+func (m Manifest) ImportedChunks(name string) []ManifestEntry {
+	var chunks []ManifestEntry
+
+	seen := map[string]bool{name: true}
+	queue := []string{name}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, key := range m[current].Imports {
+			if seen[key] {
+				continue
+			}
+
+			seen[key] = true
+
+			chunk, ok := m[key]
+			if !ok {
+				continue
+			}
+
+			chunks = append(chunks, chunk)
+			queue = append(queue, key)
+		}
+	}
+
+	return chunks
+}
@@ -0,0 +1,66 @@
+package vitgo
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseManifestFirstEntry(t *testing.T) {
+	contents := []byte(`{
+		"src/vendor.ts": {"file": "assets/vendor-0a1b2c.js"},
+		"src/main.tsx": {"file": "assets/main-4f2a1b.js", "isEntry": true, "imports": ["src/vendor.ts"], "css": ["assets/main-9c8d7e.css"]},
+		"src/admin.tsx": {"file": "assets/admin-1a2b3c.js", "isEntry": true}
+	}`)
+
+	// every run, since map order would differ
+	for i := 0; i < 20; i++ {
+		vg, err := ParseManifest(contents)
+		if err != nil {
+			t.Fatalf("ParseManifest: %v", err)
+		}
+
+		if vg.MainModule != "assets/main-4f2a1b.js" {
+			t.Fatalf("MainModule = %q, want the first entry's file", vg.MainModule)
+		}
+
+		if len(vg.Imports) != 1 || vg.Imports[0] != "assets/vendor-0a1b2c.js" {
+			t.Errorf("Imports = %q", vg.Imports)
+		}
+
+		if len(vg.CSSModule) != 1 || vg.CSSModule[0] != "assets/main-9c8d7e.css" {
+			t.Errorf("CSSModule = %q", vg.CSSModule)
+		}
+	}
+
+	if _, err := ParseManifest([]byte(`{"src/main.tsx": "assets/main.js"}`)); !errors.Is(err, ErrManifestBadlyFormed) {
+		t.Errorf("malformed manifest: err = %v, want ErrManifestBadlyFormed", err)
+	}
+}
+
+func TestParseManifestLoaded(t *testing.T) {
+	fsys := prodFS()
+	vg := newProdVitGo(t, fsys)
+
+	// what NewVitGo loaded is used, not the file
+	delete(fsys, "dist/.vite/manifest.json")
+
+	manifest, err := vg.ParseManifest()
+	if err != nil {
+		t.Fatalf("ParseManifest: %v", err)
+	}
+
+	if manifest["src/main.tsx"].File != "assets/main-4f2a1b.js" {
+		t.Errorf("ParseManifest = %v", manifest)
+	}
+
+	entry, err := vg.ResolveEntry("src/main.tsx")
+	if err != nil || entry.File != "assets/main-4f2a1b.js" {
+		t.Errorf("ResolveEntry = %v, %v", entry, err)
+	}
+
+	unloaded := &VitGo{DistFS: fstest.MapFS{}, AssetPath: "dist"}
+	if _, err := unloaded.ParseManifest(); !errors.Is(err, ErrNoManifest) {
+		t.Errorf("no manifest: err = %v, want ErrNoManifest", err)
+	}
+}
@@ -0,0 +1,135 @@
+package vitgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// exportConditions are the conditions followed, in order, when
+// an export or import target is a conditions object. source
+// and development come first, since in development the entry
+// is the unbuilt file.
+var exportConditions = []string{"source", "development", "import", "module", "browser", "default"}
+
+// UnmarshalJSON implements json.Unmarshaler for PackageJSON,
+// so "imports" (subpath imports such as #utils) can be read
+// leniently: each is mapped to a single target, conditional
+// targets are resolved through exportConditions, and anything
+// that does not resolve to a path is skipped. An "imports" that
+// is not an object is an error.
+func (p *PackageJSON) UnmarshalJSON(data []byte) error {
+	type plain PackageJSON
+
+	var content struct {
+		*plain
+		Imports json.RawMessage `json:"imports,omitempty"`
+	}
+
+	content.plain = (*plain)(p)
+
+	if err := json.Unmarshal(data, &content); err != nil {
+		return err
+	}
+
+	p.Imports = nil
+
+	if len(content.Imports) == 0 || string(content.Imports) == "null" {
+		return nil
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(content.Imports, &raw); err != nil {
+		return fmt.Errorf("imports: %w", err)
+	}
+
+	p.Imports = map[string]string{}
+	for name, value := range raw {
+		if target := resolveExportTarget(value); target != "" {
+			p.Imports[name] = target
+		}
+	}
+
+	return nil
+}
+
+// resolveExportTarget returns the path an exports or imports
+// value points at: the value itself if it is a string, or the
+// first of exportConditions that resolves in a conditions
+// object, or "".
+func resolveExportTarget(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+
+	case map[string]interface{}:
+		for _, condition := range exportConditions {
+			if target := resolveExportTarget(value[condition]); target != "" {
+				return target
+			}
+		}
+	}
+
+	return ""
+}
+
+// exportsEntry returns the file exports["."] points at, as a
+// path relative to the package, or "" if there is none or it is
+// a pattern rather than a file.
+func (p *PackageJSON) exportsEntry() string {
+	if len(p.Exports) == 0 {
+		return ""
+	}
+
+	var exports interface{}
+	if err := json.Unmarshal(p.Exports, &exports); err != nil {
+		return ""
+	}
+
+	if subpaths, ok := exports.(map[string]interface{}); ok {
+		if root, ok := subpaths["."]; ok {
+			exports = root
+		} else {
+			for key := range subpaths {
+				if strings.HasPrefix(key, ".") {
+					// subpaths, but no root export
+					return ""
+				}
+			}
+		}
+	}
+
+	target := resolveExportTarget(exports)
+	if !strings.HasPrefix(target, "./") || strings.Contains(target, "*") {
+		return ""
+	}
+
+	return strings.TrimPrefix(target, "./")
+}
+
+// probeExportsEntry points defaults.EntryPoint at the file
+// exports["."] declares, when that file exists in the project.
+// A project that names its own entry knows better than the
+// framework conventions.
+func (vc *ViteConfig) probeExportsEntry(pkgJSON *PackageJSON, defaults *JSAppParams) {
+	entry := pkgJSON.exportsEntry()
+	if entry == "" {
+		return
+	}
+
+	projectFS, err := correctFS(vc.FS, vc.FSRoot, vc.JSProjectPath)
+	if err != nil {
+		return
+	}
+
+	if info, err := fs.Stat(projectFS, entry); err != nil || info.IsDir() {
+		return
+	}
+
+	if entry != defaults.EntryPoint {
+		vc.logger().Debug("vitgo: using entry point from exports", "entry", entry)
+	}
+
+	defaults.EntryPoint = entry
+}
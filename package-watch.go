@@ -0,0 +1,113 @@
+package vitgo
+
+import (
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// packageWatch re-derives dev defaults when package.json
+// changes (see ViteConfig.WatchPackageJSON).
+type packageWatch struct {
+	mu      sync.Mutex
+	config  *ViteConfig
+	modTime time.Time
+
+	// Fields set by the user are never overridden.
+	explicitPlatform bool
+	explicitEntry    bool
+}
+
+// newPackageWatch records package.json's current modtime.
+func newPackageWatch(config *ViteConfig, explicitPlatform, explicitEntry bool) *packageWatch {
+	watch := &packageWatch{
+		config:           config,
+		explicitPlatform: explicitPlatform,
+		explicitEntry:    explicitEntry,
+	}
+
+	watch.modTime, _ = watch.stat()
+
+	return watch
+}
+
+// stat returns package.json's modtime.
+func (pw *packageWatch) stat() (time.Time, error) {
+	projectFS, err := correctFS(pw.config.FS, pw.config.FSRoot, pw.config.JSProjectPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	info, err := fs.Stat(projectFS, pw.config.packageJSONName())
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return info.ModTime(), nil
+}
+
+// current re-analyzes package.json if its modtime changed, and
+// returns the platform and entry point to use.
+func (pw *packageWatch) current() (string, string) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	config := pw.config
+
+	modTime, err := pw.stat()
+	if err != nil || modTime.Equal(pw.modTime) {
+		return config.Platform, config.EntryPoint
+	}
+
+	pw.modTime = modTime
+
+	pkgJSON, err := config.parsePackageJSON()
+	if err != nil {
+		return config.Platform, config.EntryPoint
+	}
+
+	platform := ""
+	if pw.explicitPlatform {
+		platform = config.Platform
+	}
+
+	defaults := analyzePackageJSON(pkgJSON, platform)
+	if defaults == nil {
+		return config.Platform, config.EntryPoint
+	}
+
+	config.probeEntry(defaults)
+	config.probeExportsEntry(pkgJSON, defaults)
+
+	config.DevDefaults = defaults
+
+	if !pw.explicitPlatform {
+		config.Platform = defaults.PackageType
+	}
+
+	if !pw.explicitEntry {
+		config.EntryPoint = defaults.EntryPoint
+	}
+
+	return config.Platform, config.EntryPoint
+}
+
+// watched returns vg with its platform and entry point brought
+// up to date with package.json. vg itself is not modified, so
+// this is safe to call while serving.
+func (vg *VitGo) watched() *VitGo {
+	if vg.watch == nil {
+		return vg
+	}
+
+	platform, entry := vg.watch.current()
+	if platform == vg.Platform && entry == vg.MainModule {
+		return vg
+	}
+
+	updated := *vg
+	updated.Platform = platform
+	updated.MainModule = entry
+
+	return &updated
+}
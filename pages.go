@@ -0,0 +1,72 @@
+package vitgo
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// pageCandidates lists the html files a page path may map to,
+// relative to the pages root: /about is about.html or
+// about/index.html, and / is index.html.
+func (vg *VitGo) pageCandidates(pagePath string) []string {
+	clean := strings.Trim(path.Clean("/"+pagePath), "/")
+	root := strings.Trim(vg.PagesRoot, "/")
+
+	if clean == "" {
+		return []string{path.Join(root, "index.html")}
+	}
+
+	if strings.HasSuffix(clean, ".html") {
+		return []string{path.Join(root, clean)}
+	}
+
+	return []string{
+		path.Join(root, clean+".html"),
+		path.Join(root, clean, "index.html"),
+	}
+}
+
+// PageEntry resolves a page of a multi-page app. In production
+// it returns the built file of the page's manifest entry (Vite
+// keys these by the html file path). In development it returns
+// the page's URL on the dev server.
+func (vg *VitGo) PageEntry(pagePath string) (string, error) {
+	candidates := vg.pageCandidates(pagePath)
+
+	if vg.Environment == "development" {
+		vg = vg.started()
+
+		if vg.DistFS != nil {
+			for _, candidate := range candidates {
+				if _, err := fs.Stat(vg.DistFS, candidate); err == nil {
+					return vg.BaseURL + "/" + candidate, nil
+				}
+			}
+		}
+
+		return vg.BaseURL + "/" + candidates[0], nil
+	}
+
+	vg = vg.reloaded()
+
+	if vg.manifest == nil {
+		return "", fmt.Errorf("%w: no manifest loaded", ErrUnknownEntry)
+	}
+
+	for _, candidate := range candidates {
+		entry, ok := vg.manifest[candidate]
+		if !ok {
+			continue
+		}
+
+		if entry.File == "" {
+			return "", ErrManifestBadlyFormed
+		}
+
+		return entry.File, nil
+	}
+
+	return "", fmt.Errorf("%w: page %q", ErrUnknownEntry, pagePath)
+}
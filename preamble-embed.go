@@ -0,0 +1,11 @@
+//go:build !vitgo_noreact
+
+package vitgo
+
+import "embed"
+
+// embedFiles carries the react preamble. Build with the
+// vitgo_noreact tag to leave it out of the binary.
+//
+//go:embed react
+var embedFiles embed.FS
@@ -0,0 +1,9 @@
+//go:build vitgo_noreact
+
+package vitgo
+
+import "embed"
+
+// embedFiles is empty when built with the vitgo_noreact tag;
+// requests for the react preamble then get a 404.
+var embedFiles embed.FS
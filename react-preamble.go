@@ -0,0 +1,69 @@
+package vitgo
+
+import (
+	"encoding/json"
+	"html/template"
+)
+
+// ReactRefreshPreamble returns the inline script
+// @vitejs/plugin-react needs before Vite's client when the page
+// is not served by Vite itself. It is empty in production and
+// when React is not one of the page's frameworks.
+func (vg *VitGo) ReactRefreshPreamble() template.HTML {
+	script := vg.started().reactPreambleScript()
+	if script == "" {
+		return ""
+	}
+
+	return template.HTML(`
+    <script type="module"` + string(vg.nonceAttr()) + `>` + script + `</script>`)
+}
+
+// reactPreambleScript returns the body of ReactRefreshPreamble's
+// script, which SuggestedCSP hashes, or "" when there is none.
+func (vg *VitGo) reactPreambleScript() string {
+	if vg.Environment == "production" || !vg.usesFramework("react") {
+		return ""
+	}
+
+	// json.Marshal gives a safely quoted JS string
+	refreshURL, err := json.Marshal(vg.BaseURL + "/@react-refresh")
+	if err != nil {
+		return ""
+	}
+
+	return `
+      import RefreshRuntime from ` + string(refreshURL) + `
+      RefreshRuntime.injectIntoGlobalHook(window)
+      window.$RefreshReg$ = () => {}
+      window.$RefreshSig$ = () => (type) => type
+      window.__vite_plugin_react_preamble_installed__ = true
+    `
+}
+
+// EmbeddedPreamble returns the react preamble built into the
+// package, which the file server serves as preamble.js unless
+// PreambleProvider says otherwise.
+func EmbeddedPreamble() ([]byte, error) {
+	return embedFiles.ReadFile("react/preamble.js")
+}
+
+// preamble returns the contents of preamble.js.
+func (vg *VitGo) preamble() ([]byte, error) {
+	if vg.PreambleProvider != nil {
+		return vg.PreambleProvider()
+	}
+
+	return EmbeddedPreamble()
+}
+
+// usesFramework reports whether framework is active on the page.
+func (vg *VitGo) usesFramework(framework string) bool {
+	for _, active := range vg.activeFrameworks() {
+		if active == framework {
+			return true
+		}
+	}
+
+	return false
+}
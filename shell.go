@@ -0,0 +1,42 @@
+package vitgo
+
+import (
+	"io/fs"
+	"path"
+	"regexp"
+)
+
+// mountIDs are the ids each framework's Vite template mounts on.
+var mountIDs = map[string]string{
+	"react":  "root",
+	"vue":    "app",
+	"preact": "app",
+	"svelte": "app",
+}
+
+var mountElement = regexp.MustCompile(`<div[^>]*\sid=["']([^"']+)["']`)
+
+// MountElementID returns the id of the element the app mounts
+// on. The project's index.html is checked first for a <div> with
+// an id; when it is missing, or has no such element, we fall
+// back to the framework default ("app" if unknown).
+func (vg *VitGo) MountElementID() string {
+	if vg.DistFS != nil {
+		index := "index.html"
+		if vg.Environment == "production" {
+			index = path.Join(vg.AssetPath, index)
+		}
+
+		if buf, err := fs.ReadFile(vg.DistFS, index); err == nil {
+			if matches := mountElement.FindSubmatch(buf); matches != nil {
+				return string(matches[1])
+			}
+		}
+	}
+
+	if id, ok := mountIDs[vg.Platform]; ok {
+		return id
+	}
+
+	return "app"
+}
@@ -0,0 +1,151 @@
+package vitgo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// SidecarEntry describes the precomputed variants of a single
+// asset file, as produced by a CI step.
+type SidecarEntry struct {
+	// Brotli is the path of the precompressed .br variant.
+	Brotli string `json:"br,omitempty"`
+
+	// Gzip is the path of the precompressed .gz variant.
+	Gzip string `json:"gz,omitempty"`
+
+	// Integrity is the SRI hash of the file (e.g. sha384-...).
+	Integrity string `json:"integrity,omitempty"`
+}
+
+// Sidecar maps an asset path to its precomputed variants.
+type Sidecar map[string]SidecarEntry
+
+// loadSidecar reads and validates a sidecar file from fsys.
+func loadSidecar(fsys fs.FS, sidecarPath string) (Sidecar, error) {
+	buf, err := fs.ReadFile(fsys, sidecarPath)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.DisallowUnknownFields()
+
+	sidecar := Sidecar{}
+	if err := decoder.Decode(&sidecar); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrSidecarBadlyFormed, sidecarPath, err)
+	}
+
+	for file, entry := range sidecar {
+		if file == "" {
+			return nil, fmt.Errorf("%w: empty file name", ErrSidecarBadlyFormed)
+		}
+
+		if entry.Brotli == "" && entry.Gzip == "" && entry.Integrity == "" {
+			return nil, fmt.Errorf("%w: %q has no variants", ErrSidecarBadlyFormed, file)
+		}
+
+		if entry.Integrity != "" && !validIntegrity(entry.Integrity) {
+			return nil, fmt.Errorf("%w: %q has bad integrity %q", ErrSidecarBadlyFormed, file, entry.Integrity)
+		}
+	}
+
+	return sidecar, nil
+}
+
+// validIntegrity checks that an SRI value names a supported hash.
+func validIntegrity(integrity string) bool {
+	for _, prefix := range []string{"sha256-", "sha384-", "sha512-"} {
+		if strings.HasPrefix(integrity, prefix) && len(integrity) > len(prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sidecarEntry returns the precomputed entry for a file, if any.
+// Callers fall back to computing values at runtime when missing.
+func (vg *VitGo) sidecarEntry(file string) (SidecarEntry, bool) {
+	if vg.Sidecar == nil {
+		return SidecarEntry{}, false
+	}
+
+	entry, ok := vg.Sidecar[strings.TrimPrefix(file, "/")]
+
+	return entry, ok
+}
+
+// expectedIntegrity returns the known SRI value of a file: from
+// the sidecar if present, else from the manifest's integrity
+// field. Empty means it has to be computed.
+func (vg *VitGo) expectedIntegrity(file string) string {
+	if entry, ok := vg.sidecarEntry(file); ok && entry.Integrity != "" {
+		return entry.Integrity
+	}
+
+	return vg.integrity[strings.TrimPrefix(file, "/")]
+}
+
+// verifyFile checks name against its expected integrity hash
+// from the sidecar or manifest. Files without an expected hash, or larger
+// than MaxInlineOpBytes, are not checked.
+func (vg *VitGo) verifyFile(fsys fs.FS, name string) error {
+	expectedIntegrity := vg.expectedIntegrity(name)
+	if expectedIntegrity == "" {
+		return nil
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		// let the file server report missing files
+		return nil
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return nil
+	}
+
+	if vg.MaxInlineOpBytes > 0 && info.Size() > vg.MaxInlineOpBytes {
+		return nil
+	}
+
+	algo, expected, _ := strings.Cut(expectedIntegrity, "-")
+
+	var hasher hash.Hash
+	switch algo {
+	case "sha256":
+		hasher = sha256.New()
+	case "sha384":
+		hasher = sha512.New384()
+	case "sha512":
+		hasher = sha512.New()
+	default:
+		return fmt.Errorf("%w: %q", ErrSidecarBadlyFormed, expectedIntegrity)
+	}
+
+	if _, err := io.Copy(hasher, f); err != nil {
+		return err
+	}
+
+	actual := base64.StdEncoding.EncodeToString(hasher.Sum(nil))
+	if actual != expected {
+		return fmt.Errorf("%w: %s", ErrIntegrityMismatch, name)
+	}
+
+	return nil
+}
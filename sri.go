@@ -0,0 +1,77 @@
+package vitgo
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// sriCache holds the integrity hashes computed for EnableSRI,
+// so each file is only hashed once per process.
+type sriCache struct {
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+// integrityAttr returns the integrity attribute, with a leading
+// space, for a built file when EnableSRI is set. Hashes from
+// the sidecar or manifest are used as they are; otherwise the
+// file is read from DistFS and hashed with SHA-384.
+func (vg *VitGo) integrityAttr(file string) (template.HTMLAttr, error) {
+	if !vg.EnableSRI || vg.Environment != "production" || isAbsoluteURL(file) {
+		return "", nil
+	}
+
+	integrity := vg.expectedIntegrity(file)
+	if integrity == "" {
+		var err error
+
+		integrity, err = vg.computeIntegrity(file)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return template.HTMLAttr(` integrity="` + integrity + `"`), nil
+}
+
+// computeIntegrity hashes a built file, caching the result.
+func (vg *VitGo) computeIntegrity(file string) (string, error) {
+	name := path.Join(vg.AssetPath, strings.TrimPrefix(file, "/"))
+
+	if vg.sriCache != nil {
+		vg.sriCache.mu.Lock()
+		defer vg.sriCache.mu.Unlock()
+
+		if integrity, ok := vg.sriCache.hashes[name]; ok {
+			return integrity, nil
+		}
+	}
+
+	if vg.DistFS == nil {
+		return "", fmt.Errorf("could not hash %s for integrity: no DistFS", name)
+	}
+
+	buf, err := fs.ReadFile(vg.DistFS, name)
+	if err != nil {
+		return "", fmt.Errorf("could not hash %s for integrity: %w", name, err)
+	}
+
+	sum := sha512.Sum384(buf)
+	integrity := "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+
+	if vg.sriCache != nil {
+		if vg.sriCache.hashes == nil {
+			vg.sriCache.hashes = map[string]string{}
+		}
+
+		vg.sriCache.hashes[name] = integrity
+	}
+
+	return integrity, nil
+}
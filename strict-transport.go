@@ -0,0 +1,44 @@
+package vitgo
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// DEFAULT_HSTS_MAX_AGE is one year, in seconds.
+const DEFAULT_HSTS_MAX_AGE = 31536000
+
+// StrictTransport wraps an asset handler so plain-HTTP requests
+// are redirected to HTTPS (when RedirectHTTPS is set) and
+// HTTPS responses carry Strict-Transport-Security (when HSTS is
+// set). The two are independent of each other.
+func (vg *VitGo) StrictTransport(next http.Handler) http.Handler {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		secure := isSecureRequest(r)
+
+		if !secure && vg.RedirectHTTPS {
+			target := "https://" + r.Host + r.URL.RequestURI()
+			http.Redirect(w, r, target, http.StatusPermanentRedirect)
+			return
+		}
+
+		if secure && vg.HSTS {
+			maxAge := vg.HSTSMaxAge
+			if maxAge <= 0 {
+				maxAge = DEFAULT_HSTS_MAX_AGE
+			}
+
+			w.Header().Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d", maxAge))
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(handler)
+}
+
+// isSecureRequest reports whether the request came in over TLS,
+// either directly or through a TLS-terminating proxy.
+func isSecureRequest(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
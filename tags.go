@@ -2,44 +2,641 @@ package vitgo
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
 	"html/template"
+	"io/fs"
+	"path"
+	"strings"
 )
 
+// validateLoadStrategy checks a requested load strategy and
+// returns the strategy to use, defaulting to "module".
+func validateLoadStrategy(strategy string) (string, error) {
+	switch strategy {
+	case "":
+		return LOAD_MODULE, nil
+
+	case LOAD_MODULE, LOAD_MODULE_ASYNC, LOAD_DEFER, LOAD_ASYNC:
+		return strategy, nil
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrBadLoadStrategy, strategy)
+}
+
+// scriptAttrs returns the attributes for the entry script tag.
+// Vite builds ES modules, which only load as type="module", so
+// that is never dropped. Module scripts are already deferred by
+// the browser, so defer adds nothing; async is the only
+// meaningful extra.
+func (vg *VitGo) scriptAttrs() template.HTMLAttr {
+	switch vg.LoadStrategy {
+	case LOAD_MODULE_ASYNC, LOAD_ASYNC:
+		return `type="module" async`
+	}
+
+	return `type="module"`
+}
+
+// trackAttr returns the Turbo tracking attribute, with a leading
+// space, for production tags when TurboTrack is set. Asset
+// hashes only change across deploys in production.
+func (vg *VitGo) trackAttr() template.HTMLAttr {
+	if !vg.TurboTrack || vg.Environment != "production" {
+		return ""
+	}
+
+	return ` data-turbo-track="reload"`
+}
+
+// scriptCrossorigin returns the crossorigin attribute, with a
+// leading space, for the production entry script: always there
+// unless Crossorigin turns it off.
+func (vg *VitGo) scriptCrossorigin() template.HTMLAttr {
+	if vg.Crossorigin != nil && !*vg.Crossorigin {
+		return ""
+	}
+
+	return ` crossorigin`
+}
+
+// preloadCrossorigin returns the crossorigin attribute, with a
+// leading space, for modulepreload and stylesheet links.
+// Preloads are only reused by a crossorigin script if they
+// match, and integrity needs CORS off-origin, so they get it
+// when assets come from a CDN BasePath, or as Crossorigin says.
+func (vg *VitGo) preloadCrossorigin() template.HTMLAttr {
+	if vg.Crossorigin != nil {
+		if *vg.Crossorigin {
+			return ` crossorigin`
+		}
+
+		return ""
+	}
+
+	if isAbsoluteURL(vg.BasePath) {
+		return ` crossorigin`
+	}
+
+	return ""
+}
+
+// nonceAttr returns the CSP nonce attribute, with a leading
+// space, when a nonce was set with WithNonce.
+func (vg *VitGo) nonceAttr() template.HTMLAttr {
+	if vg.nonce == "" {
+		return ""
+	}
+
+	return template.HTMLAttr(` nonce="` + html.EscapeString(vg.nonce) + `"`)
+}
+
+// WithNonce returns a copy of vg whose tags, inline scripts
+// and inline styles all carry nonce, for a Content-Security-
+// Policy using nonces. Nonces must be unique per response, so
+// call it for each request.
+func (vg *VitGo) WithNonce(nonce string) *VitGo {
+	withNonce := *vg
+	withNonce.nonce = nonce
+
+	return &withNonce
+}
+
+// RenderTagsWithNonce is RenderTags with nonce added to every
+// tag (see WithNonce).
+func (vg *VitGo) RenderTagsWithNonce(entry, nonce string) (template.HTML, error) {
+	return vg.WithNonce(nonce).RenderTags(entry)
+}
+
+// AssetURL returns the URL for a built file named in the
+// manifest. Files that are already absolute, either a full URL
+// (when Vite's base is a URL) or root-relative, are returned
+// unchanged; anything else is put under BasePath, or made
+// root-relative.
+func (vg *VitGo) AssetURL(file string) string {
+	if isAbsoluteURL(file) || strings.HasPrefix(file, "/") {
+		return file
+	}
+
+	if vg.BasePath != "" {
+		return strings.TrimSuffix(vg.BasePath, "/") + "/" + file
+	}
+
+	return "/" + file
+}
+
+// localBasePath returns BasePath, without its trailing slash,
+// when it is a subpath of this server, or "" otherwise.
+func (vg *VitGo) localBasePath() string {
+	if isAbsoluteURL(vg.BasePath) {
+		return ""
+	}
+
+	return strings.TrimSuffix(vg.BasePath, "/")
+}
+
+// isAbsoluteURL reports whether u has a scheme or is
+// protocol-relative (//host/...).
+func isAbsoluteURL(u string) bool {
+	return strings.HasPrefix(u, "//") ||
+		strings.HasPrefix(u, "http://") ||
+		strings.HasPrefix(u, "https://")
+}
+
+// devPreambleTags holds the dev-only setup each framework needs
+// before the entry script. Frameworks not listed need none.
+var devPreambleTags = map[string]string{
+	// react requires some extra help to load
+	"react": `
+    <script{{ .NonceAttr }} src="/src/preamble.js"></script>
+            `,
+}
+
+// activeFrameworks returns the frameworks on the page: the
+// explicit Frameworks set, or else just Platform.
+func (vg *VitGo) activeFrameworks() []string {
+	if len(vg.Frameworks) > 0 {
+		return vg.Frameworks
+	}
+
+	return []string{vg.Platform}
+}
+
+// viteClientTag loads Vite's HMR client from the dev server.
+const viteClientTag = `
+    <script type="module"{{ .NonceAttr }} src="{{ devClient }}"></script>`
+
+// devClientURL returns where Vite's client is loaded from:
+// DevClientURL if set, else the dev server.
+func (vg *VitGo) devClientURL() string {
+	if vg.DevClientURL != "" {
+		return vg.DevClientURL
+	}
+
+	return vg.BaseURL + "/@vite/client"
+}
+
+// devPreambles returns the dev setup tags: the setup for every
+// active framework, then Vite's client, each emitted only once.
+func (vg *VitGo) devPreambles() string {
+	var tags string
+	seen := map[string]bool{}
+
+	for _, framework := range vg.activeFrameworks() {
+		tag, ok := devPreambleTags[framework]
+		if !ok || seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		tags += tag
+	}
+
+	return tags + viteClientTag
+}
+
+// forInput returns a copy of vg pointing at the assets of an
+// entry, given either as an input name (see ViteConfig.Inputs)
+// or as a source path such as src/main.tsx. With no name, vg
+// itself is returned.
+func (vg *VitGo) forInput(name ...string) (*VitGo, error) {
+	vg = vg.reloaded().started()
+
+	if len(name) == 0 || name[0] == "" {
+		return vg, nil
+	}
+
+	src, ok := vg.Inputs[name[0]]
+	if !ok {
+		return vg.forEntry(name[0])
+	}
+
+	input := *vg
+
+	if vg.Environment == "development" {
+		input.MainModule = src
+		return &input, nil
+	}
+
+	assets, ok := vg.inputs[name[0]]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownEntry, name[0])
+	}
+
+	input.MainModule = assets.MainModule
+	input.Imports = assets.Imports
+	input.CSSModule = assets.CSSModule
+
+	return &input, nil
+}
+
+// forEntry returns a copy of vg pointing at the assets of a
+// source path: in development the dev server path, and in
+// production the hashed file from the manifest along with every
+// chunk and stylesheet it pulls in.
+func (vg *VitGo) forEntry(entry string) (*VitGo, error) {
+	input := *vg
+
+	if vg.Environment == "development" {
+		prefix := strings.Trim(vg.URLPrefix, "/")
+
+		input.MainModule = entry
+		if prefix != "" && !strings.HasPrefix(entry, prefix+"/") {
+			input.MainModule = path.Join(prefix, entry)
+		}
+
+		return &input, nil
+	}
+
+	manifest, err := vg.ParseManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := manifest[entry]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownEntry, entry)
+	}
+
+	assets, err := manifest.entryAssets(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	input.MainModule = assets.MainModule
+	input.Imports = assets.Imports
+	input.CSSModule = assets.CSSModule
+
+	return &input, nil
+}
+
 // RenderTags genarates the HTML tags that link a rendered
 // Go template with any Vue assets that need to be loaded.
-func (vg *VitGo) RenderTags() (template.HTML, error) {
+// An optional entry, either an input name (see
+// ViteConfig.Inputs) or a source path like src/main.tsx,
+// selects which entry to render; the default is the main entry.
+//
+// It is the same as RenderScriptTags, RenderPreloadTags and
+// RenderCSSTags run one after the other.
+func (vg *VitGo) RenderTags(name ...string) (template.HTML, error) {
+	var tags template.HTML
+
+	for _, render := range []func(...string) (template.HTML, error){
+		vg.RenderScriptTags,
+		vg.RenderPreloadTags,
+		vg.RenderCSSTags,
+	} {
+		group, err := render(name...)
+		if err != nil {
+			return "", err
+		}
+
+		tags += group
+	}
+
+	return tags, nil
+}
+
+// RenderScriptTags renders the entry script, along with any
+// framework preambles in development. With SSRNoClient set, or
+// for astro, whose pages carry their own tags, there is no
+// client script, so it renders nothing.
+func (vg *VitGo) RenderScriptTags(name ...string) (template.HTML, error) {
+	if vg.SSRNoClient || (vg.Platform == "astro" && len(name) == 0) {
+		return "", nil
+	}
+
+	vg = vg.watched()
+
 	var tags string
 
 	if vg.Environment == "development" {
-		if vg.Platform == "react" {
-			// react requires some extra help to load
-			tags += `
-    <script src="/src/preamble.js"></script>
-            `
-		}
+		tags += vg.devPreambles()
+	}
+
+	tags += vg.entryScriptTemplate()
+
+	return vg.renderTemplate(tags, name...)
+}
 
-		tags += `
-    <script type="module" src="{{.BaseURL}}/{{ .MainModule }}"></script>
+// entryScriptTemplate is the template for the entry script tag.
+func (vg *VitGo) entryScriptTemplate() string {
+	if vg.Environment == "development" {
+		return `
+    <script {{ .ScriptAttrs }}{{ .NonceAttr }} src="{{.BaseURL}}/{{ .MainModule }}"></script>
         `
-	} else {
-		tags += `
-	<script type="module" crossorigin src="/{{ .MainModule }}"></script>
-	{{ range .Imports }}
-	<link rel="modulepreload" href="/{{.}}">
-	{{ end }}
+	}
+
+	return `
+	<script {{ .ScriptAttrs }}{{ .ScriptCrossorigin }}{{ .TrackAttr }}{{ .NonceAttr }}{{ integrity .MainModule }} src="{{ asset .MainModule }}"></script>`
+}
+
+// RenderTagsMulti renders the tags for several entries (input
+// names, see ViteConfig.Inputs) on one page. Scripts are emitted
+// per entry in the order given; preloads and CSS are merged so
+// a chunk shared by several entries is only loaded once.
+func (vg *VitGo) RenderTagsMulti(entries []string) (template.HTML, error) {
+	var tags template.HTML
+
+	vg = vg.reloaded()
+
+	if !vg.SSRNoClient {
+		vg = vg.watched()
+
+		if vg.Environment == "development" {
+			preambles, err := vg.renderTemplate(vg.devPreambles())
+			if err != nil {
+				return "", err
+			}
+
+			tags += preambles
+		}
+	}
+
+	merged := *vg
+	merged.Imports = nil
+	merged.CSSModule = nil
+
+	seen := map[string]bool{}
+
+	for _, entry := range entries {
+		input, err := vg.forInput(entry)
+		if err != nil {
+			return "", err
+		}
+
+		if !vg.SSRNoClient {
+			script, err := vg.renderTemplate(vg.entryScriptTemplate(), entry)
+			if err != nil {
+				return "", err
+			}
+
+			tags += script
+		}
+
+		// an entry's own file never needs a preload
+		seen[input.MainModule] = true
+	}
+
+	for _, entry := range entries {
+		input, _ := vg.forInput(entry)
+
+		for _, file := range input.Imports {
+			if !seen[file] {
+				seen[file] = true
+				merged.Imports = append(merged.Imports, file)
+			}
+		}
+
+		for _, file := range input.CSSModule {
+			if !seen[file] {
+				seen[file] = true
+				merged.CSSModule = append(merged.CSSModule, file)
+			}
+		}
+	}
+
+	for _, render := range []func(...string) (template.HTML, error){
+		merged.RenderPreloadTags,
+		merged.RenderCSSTags,
+	} {
+		group, err := render()
+		if err != nil {
+			return "", err
+		}
+
+		tags += group
+	}
+
+	return tags, nil
+}
+
+// RenderPreloadTags renders modulepreload links for the entry's
+// imports. Development has none, Vite loads them on demand.
+func (vg *VitGo) RenderPreloadTags(name ...string) (template.HTML, error) {
+	if vg.Environment == "development" {
+		return vg.renderTemplate("", name...)
+	}
+
+	return vg.renderTemplate(`
+	{{ range preloads }}
+	<link rel="modulepreload"{{ $.PreloadCrossorigin }}{{ $.NonceAttr }}{{ integrity . }} href="{{ asset . }}">
+	{{ end }}`, name...)
+}
+
+// RenderCSSTags renders stylesheet links for the entry's CSS.
+// Development has none, Vite injects CSS from JS.
+func (vg *VitGo) RenderCSSTags(name ...string) (template.HTML, error) {
+	if vg.Environment == "development" {
+		return vg.renderTemplate("", name...)
+	}
+
+	return vg.renderTemplate(`
 	{{ range .CSSModule }}
-	<link rel="stylesheet" href="/{{.}}">
+	{{ with inlineCSS . }}
+	<style{{ $.NonceAttr }}>{{ . }}</style>
+	{{ else }}
+	<link rel="stylesheet"{{ $.PreloadCrossorigin }}{{ $.TrackAttr }}{{ $.NonceAttr }}{{ integrity . }} href="{{ asset . }}">
+	{{ end }}
 	{{ end }}
-	`
+	`, name...)
+}
+
+// RenderScripts renders an entry's scripts ("" for the main
+// entry): the module script and modulepreloads for the chunks
+// it imports in production, Vite's client, any framework
+// preambles and the entry script in development. With
+// RenderStyles in <head>, it lets the scripts go at the end of
+// <body>.
+func (vg *VitGo) RenderScripts(entry string) (template.HTML, error) {
+	var name []string
+	if entry != "" {
+		name = []string{entry}
+	}
+
+	scripts, err := vg.RenderScriptTags(name...)
+	if err != nil {
+		return "", err
+	}
+
+	preloads, err := vg.RenderPreloadTags(name...)
+	if err != nil {
+		return "", err
+	}
+
+	return scripts + preloads, nil
+}
+
+// RenderStyles renders stylesheet links for an entry's CSS,
+// including the CSS of the chunks it imports. Unlike
+// RenderCSSTags it always links, never inlines, and takes one
+// entry ("" for the main one). Development has none, Vite
+// injects CSS from JS.
+func (vg *VitGo) RenderStyles(entry string) (template.HTML, error) {
+	if vg.Environment == "development" {
+		return "", nil
+	}
+
+	input, err := vg.forStyles(entry)
+	if err != nil {
+		return "", err
+	}
+
+	return input.renderTemplate(`
+	{{ range .CSSModule }}
+	<link rel="stylesheet"{{ $.PreloadCrossorigin }}{{ $.TrackAttr }}{{ $.NonceAttr }}{{ integrity . }} href="{{ asset . }}">
+	{{ end }}`)
+}
+
+// EntryStyles returns the URLs of the stylesheets RenderStyles
+// links, for callers that inline them instead. It is empty in
+// development.
+func (vg *VitGo) EntryStyles(entry string) ([]string, error) {
+	if vg.Environment == "development" {
+		return nil, nil
+	}
+
+	input, err := vg.forStyles(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, 0, len(input.CSSModule))
+	for _, file := range input.CSSModule {
+		urls = append(urls, input.AssetURL(file))
+	}
+
+	return urls, nil
+}
+
+// forStyles is forInput for RenderStyles and EntryStyles, with
+// the assets pinned so a reload can't swap CSSModule underneath.
+func (vg *VitGo) forStyles(entry string) (*VitGo, error) {
+	input, err := vg.forInput(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	styled := *input
+	styled.manifestWatch = nil
+
+	return &styled, nil
+}
+
+// preloads returns the imports to preload, at most MaxPreloads
+// of them. Imports are gathered breadth first (see
+// Manifest.ImportedChunks), so the entry's direct imports are
+// the ones kept.
+func (vg *VitGo) preloads() []string {
+	if vg.MaxPreloads > 0 && len(vg.Imports) > vg.MaxPreloads {
+		return vg.Imports[:vg.MaxPreloads]
+	}
+
+	return vg.Imports
+}
+
+// inlineCSS returns the contents of a CSS file to inline when it
+// is smaller than CSSInlineThreshold, or "" to link it instead.
+func (vg *VitGo) inlineCSS(file string) template.CSS {
+	if vg.CSSInlineThreshold <= 0 || vg.DistFS == nil || isAbsoluteURL(file) {
+		return ""
 	}
 
-	tmpl, err := template.New("tags").Parse(tags)
+	name := path.Join(vg.AssetPath, strings.TrimPrefix(file, "/"))
+
+	info, err := fs.Stat(vg.DistFS, name)
+	if err != nil || info.Size() >= int64(vg.CSSInlineThreshold) {
+		return ""
+	}
+
+	if vg.MaxInlineOpBytes > 0 && info.Size() > vg.MaxInlineOpBytes {
+		return ""
+	}
+
+	buf, err := fs.ReadFile(vg.DistFS, name)
+	if err != nil {
+		vg.logger().Error("could not inline css", "error", err)
+		return ""
+	}
+
+	// never let the stylesheet close the style tag early
+	if strings.Contains(strings.ToLower(string(buf)), "</style") {
+		return ""
+	}
+
+	return template.CSS(buf)
+}
+
+// renderTemplate executes a tag template for the named input.
+func (vg *VitGo) renderTemplate(tags string, name ...string) (template.HTML, error) {
+	vg, err := vg.forInput(name...)
+	if err != nil {
+		return "", err
+	}
+
+	tmpl, err := template.New("tags").Funcs(template.FuncMap{
+		"asset":     vg.AssetURL,
+		"inlineCSS": vg.inlineCSS,
+		"preloads":  vg.preloads,
+		"devClient": vg.devClientURL,
+		"integrity": vg.integrityAttr,
+	}).Parse(tags)
 	if err != nil {
 		return "", err
 	}
 
+	data := struct {
+		*VitGo
+		ScriptAttrs template.HTMLAttr
+		TrackAttr   template.HTMLAttr
+		NonceAttr   template.HTMLAttr
+
+		ScriptCrossorigin  template.HTMLAttr
+		PreloadCrossorigin template.HTMLAttr
+	}{
+		VitGo:       vg,
+		ScriptAttrs: vg.scriptAttrs(),
+		TrackAttr:   vg.trackAttr(),
+		NonceAttr:   vg.nonceAttr(),
+
+		ScriptCrossorigin:  vg.scriptCrossorigin(),
+		PreloadCrossorigin: vg.preloadCrossorigin(),
+	}
+
 	var buffer bytes.Buffer
-	tmpl.Execute(&buffer, vg)
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", err
+	}
 
 	return template.HTML(buffer.String()), nil
 }
+
+// RenderShellWithState renders an inline script assigning state,
+// JSON encoded, to window.__INITIAL_STATE__, followed by the tags
+// for entry (see RenderTags), so the client can hydrate.
+func (vg *VitGo) RenderShellWithState(entry string, state any) (template.HTML, error) {
+	script, err := stateScript(state)
+	if err != nil {
+		return "", err
+	}
+
+	tags, err := vg.RenderTags(entry)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(`
+    <script`+string(vg.nonceAttr())+`>`+script+`</script>`) + tags, nil
+}
+
+// stateScript returns the body of RenderShellWithState's script.
+func stateScript(state any) (string, error) {
+	// json.Marshal escapes <, > and & so the encoded state
+	// can not close the script tag early.
+	encoded, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+
+	return `window.__INITIAL_STATE__ = ` + string(encoded) + `;`, nil
+}
@@ -0,0 +1,219 @@
+package vitgo
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// manifestFS is prodFS with the main entry built to file.
+func manifestFS(file string) fstest.MapFS {
+	fsys := prodFS()
+	fsys["dist/.vite/manifest.json"] = &fstest.MapFile{Data: []byte(`{
+		"src/main.tsx": {"file": "` + file + `", "src": "src/main.tsx", "isEntry": true}
+	}`)}
+
+	return fsys
+}
+
+func TestManifestFileURLs(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		basePath string
+		want     string
+	}{
+		{"relative", "assets/main-4f2a1b.js", "", "/assets/main-4f2a1b.js"},
+		{"relative with base", "assets/main-4f2a1b.js", "/app/", "/app/assets/main-4f2a1b.js"},
+		{"relative with CDN base", "assets/main-4f2a1b.js", "https://cdn.example.com/", "https://cdn.example.com/assets/main-4f2a1b.js"},
+		{"root-absolute", "/static/main-4f2a1b.js", "/app/", "/static/main-4f2a1b.js"},
+		{"absolute", "https://cdn.example.com/assets/main-4f2a1b.js", "https://cdn.example.com/", "https://cdn.example.com/assets/main-4f2a1b.js"},
+		{"protocol-relative", "//cdn.example.com/assets/main-4f2a1b.js", "/app/", "//cdn.example.com/assets/main-4f2a1b.js"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vg := newProdVitGo(t, manifestFS(tt.file))
+			vg.BasePath = tt.basePath
+
+			if got := vg.AssetURL(tt.file); got != tt.want {
+				t.Errorf("AssetURL(%q) = %q, want %q", tt.file, got, tt.want)
+			}
+
+			tags, err := vg.RenderTags()
+			if err != nil {
+				t.Fatalf("RenderTags: %v", err)
+			}
+
+			if !strings.Contains(string(tags), `src="`+tt.want+`"`) {
+				t.Errorf("RenderTags() = %s, want src %q", tags, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadStrategy(t *testing.T) {
+	prod := newProdVitGo(t, prodFS())
+
+	dev, err := NewVitGo(&ViteConfig{
+		FS: fstest.MapFS{
+			"frontend/package.json": {Data: []byte(`{"dependencies": {"vue": "^3.4.0"}, "devDependencies": {"vite": "^5.0.0"}}`)},
+		},
+		Environment:    "development",
+		DisableLogging: true,
+	})
+	if err != nil {
+		t.Fatalf("NewVitGo: %v", err)
+	}
+
+	tests := []struct {
+		strategy string
+		want     string
+	}{
+		{"", `<script type="module" `},
+		{LOAD_MODULE, `<script type="module" `},
+		{LOAD_DEFER, `<script type="module" `},
+		{LOAD_MODULE_ASYNC, `<script type="module" async `},
+		{LOAD_ASYNC, `<script type="module" async `},
+	}
+
+	for env, base := range map[string]*VitGo{"production": prod, "development": dev} {
+		for _, tt := range tests {
+			vg := *base
+			vg.LoadStrategy = tt.strategy
+
+			tags, err := vg.RenderScriptTags()
+			if err != nil {
+				t.Fatalf("RenderScriptTags: %v", err)
+			}
+
+			// the entry script is the last one
+			html := string(tags)
+			entry := html[strings.LastIndex(html, "<script"):]
+
+			if !strings.HasPrefix(entry, tt.want) {
+				t.Errorf("%s %q: entry tag = %s, want it to start %s", env, tt.strategy, entry, tt.want)
+			}
+
+			if strings.Contains(html, "defer") {
+				t.Errorf("%s %q: tags = %s, want no defer", env, tt.strategy, html)
+			}
+		}
+	}
+}
+
+// chunkFS is prodFS with two entries importing a shared chunk,
+// which in turn imports a chunk of its own, each with CSS.
+func chunkFS() fstest.MapFS {
+	fsys := prodFS()
+	fsys["dist/.vite/manifest.json"] = &fstest.MapFile{Data: []byte(`{
+		"src/main.tsx": {"file": "assets/main-4f2a1b.js", "isEntry": true, "imports": ["_shared-Ab12cd34.js"], "css": ["assets/main-9c8d7e.css"]},
+		"src/admin.tsx": {"file": "assets/admin-1a2b3c.js", "isEntry": true, "imports": ["_shared-Ab12cd34.js"], "css": ["assets/admin-5e6f7a.css"]},
+		"_shared-Ab12cd34.js": {"file": "assets/shared-Ab12cd34.js", "imports": ["_deep-Cd34ef56.js"], "css": ["assets/shared-Ef56ab78.css"]},
+		"_deep-Cd34ef56.js": {"file": "assets/deep-Cd34ef56.js", "css": ["assets/deep-Gh78ij90.css"]}
+	}`)}
+
+	return fsys
+}
+
+// newInputsVitGo returns a production VitGo for chunkFS with
+// admin as a named input.
+func newInputsVitGo(t *testing.T) *VitGo {
+	t.Helper()
+
+	vg, err := NewVitGo(&ViteConfig{
+		FS:             chunkFS(),
+		FSRoot:         ".",
+		Environment:    "production",
+		Platform:       "react",
+		Inputs:         map[string]string{"admin": "src/admin.tsx"},
+		DisableLogging: true,
+	})
+	if err != nil {
+		t.Fatalf("NewVitGo: %v", err)
+	}
+
+	return vg
+}
+
+func TestEntryStylesImportedChunks(t *testing.T) {
+	vg := newInputsVitGo(t)
+
+	tests := []struct {
+		entry string
+		want  []string
+	}{
+		{"", []string{"/assets/main-9c8d7e.css", "/assets/shared-Ef56ab78.css", "/assets/deep-Gh78ij90.css"}},
+		{"admin", []string{"/assets/admin-5e6f7a.css", "/assets/shared-Ef56ab78.css", "/assets/deep-Gh78ij90.css"}},
+		{"src/admin.tsx", []string{"/assets/admin-5e6f7a.css", "/assets/shared-Ef56ab78.css", "/assets/deep-Gh78ij90.css"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			got, err := vg.EntryStyles(tt.entry)
+			if err != nil {
+				t.Fatalf("EntryStyles: %v", err)
+			}
+
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("EntryStyles(%q) = %q, want %q", tt.entry, got, tt.want)
+			}
+
+			styles, err := vg.RenderStyles(tt.entry)
+			if err != nil {
+				t.Fatalf("RenderStyles: %v", err)
+			}
+
+			for _, href := range tt.want {
+				if !strings.Contains(string(styles), `href="`+href+`"`) {
+					t.Errorf("RenderStyles(%q) = %s, missing %s", tt.entry, styles, href)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderTagsImportedChunks(t *testing.T) {
+	for _, entry := range []string{"", "admin", "src/admin.tsx"} {
+		t.Run(entry, func(t *testing.T) {
+			vg := newInputsVitGo(t)
+
+			tags, err := vg.RenderTags(entry)
+			if err != nil {
+				t.Fatalf("RenderTags: %v", err)
+			}
+
+			for _, want := range []string{
+				`rel="modulepreload"`,
+				"/assets/shared-Ab12cd34.js",
+				"/assets/deep-Cd34ef56.js",
+				"/assets/shared-Ef56ab78.css",
+				"/assets/deep-Gh78ij90.css",
+			} {
+				if !strings.Contains(string(tags), want) {
+					t.Errorf("RenderTags(%q) = %s, missing %s", entry, tags, want)
+				}
+			}
+
+			// the cap keeps the direct import, and never drops CSS
+			vg.MaxPreloads = 1
+
+			tags, err = vg.RenderTags(entry)
+			if err != nil {
+				t.Fatalf("RenderTags: %v", err)
+			}
+
+			if n := strings.Count(string(tags), `rel="modulepreload"`); n != 1 {
+				t.Errorf("MaxPreloads 1: %d modulepreloads, want 1", n)
+			}
+
+			if strings.Contains(string(tags), "deep-Cd34ef56.js") || !strings.Contains(string(tags), "shared-Ab12cd34.js") {
+				t.Errorf("MaxPreloads 1: %s, want only the direct import preloaded", tags)
+			}
+
+			if !strings.Contains(string(tags), "/assets/deep-Gh78ij90.css") {
+				t.Errorf("MaxPreloads 1: %s, want the deep chunk's CSS kept", tags)
+			}
+		})
+	}
+}
@@ -1,14 +1,22 @@
 package vitgo
 
 import (
-	"embed"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
+	"net"
+	"net/url"
+	"path"
 	"regexp"
+	"strings"
+	"sync"
 )
 
+// pwaPlugins are the Vite plugins that register a service
+// worker.
+var pwaPlugins = []string{"vite-plugin-pwa", "@vite-pwa/sveltekit", "@vite-pwa/nuxt", "@vite-pwa/astro"}
+
 type PackageJSON struct {
 	Name            string            `json:"name"`
 	Version         string            `json:"version"`
@@ -16,33 +24,95 @@ type PackageJSON struct {
 	Scripts         map[string]string `json:"scripts"`
 	Dependencies    map[string]string `json:"dependencies"`
 	DevDependencies map[string]string `json:"devDependencies"`
+	Engines         Engines           `json:"engines"`
+
+	// Exports is kept raw: it may be a string, a map of
+	// subpaths or a map of conditions. See exportsEntry.
+	Exports json.RawMessage `json:"exports,omitempty"`
+
+	// Imports maps subpath imports to their targets; see
+	// UnmarshalJSON.
+	Imports map[string]string `json:"imports,omitempty"`
+}
+
+// Engines holds package.json's "engines" (e.g. node version).
+// It is informational, so it is parsed leniently: a malformed
+// block is ignored rather than failing the whole package.json.
+type Engines map[string]string
+
+// UnmarshalJSON implements json.Unmarshaler for Engines,
+// keeping string values and skipping anything else.
+func (e *Engines) UnmarshalJSON(data []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil
+	}
+
+	engines := Engines{}
+	for name, value := range raw {
+		if version, ok := value.(string); ok {
+			engines[name] = version
+		}
+	}
+
+	*e = engines
+
+	return nil
 }
 
 type JSAppParams struct {
-	JSHash        string `json:"hash"`
-	ViteVersion   string `json:"vite_version"`
-	ViteMajorVer  string `json:"vite_major_version"`
-	PackageType   string `json:"package_type"`
-	MajorVer      string `json:"major_version,omitempty"`
-	EntryPoint    string `json:"entry_point"`
-	HasTypeScript bool   `json:"has_ts"`
-	IsVanilla     bool   `json:"is_vanilla,omitempty"`
-	VueVersion    string `json:"vue_version,omitempty"`
-	ReactVersion  string `json:"react_version,omitempty"`
-	PreactVersion string `json:"preact_version,omitempty"`
-	SvelteVersion string `json:"svelte_version,omitempty"`
-	LitVersion    string `json:"lit_version,omitempty"`
+	JSHash         string `json:"hash"`
+	ViteVersion    string `json:"vite_version"`
+	ViteMajorVer   string `json:"vite_major_version"`
+	PackageType    string `json:"package_type"`
+	MajorVer       string `json:"major_version,omitempty"`
+	EntryPoint     string `json:"entry_point"`
+	HasTypeScript  bool   `json:"has_ts"`
+	IsVanilla      bool   `json:"is_vanilla,omitempty"`
+	VueVersion     string `json:"vue_version,omitempty"`
+	ReactVersion   string `json:"react_version,omitempty"`
+	PreactVersion  string `json:"preact_version,omitempty"`
+	SvelteVersion  string `json:"svelte_version,omitempty"`
+	LitVersion     string `json:"lit_version,omitempty"`
+	SolidVersion   string `json:"solid_version,omitempty"`
+	AstroVersion   string `json:"astro_version,omitempty"`
+	QwikVersion    string `json:"qwik_version,omitempty"`
+	AngularVersion string `json:"angular_version,omitempty"`
+
+	// BestEffort is set for platforms (lit, qwik) that do not
+	// fit the single client bundle model, where RenderTags can
+	// only approximate what the app needs.
+	BestEffort bool `json:"best_effort,omitempty"`
+
+	// VueMajorVer is "2" or "3"; Vue 2 projects may need the
+	// runtime+compiler build, flagged by HasVueTemplateCompiler.
+	VueMajorVer            string `json:"vue_major_version,omitempty"`
+	HasVueTemplateCompiler bool   `json:"has_vue_template_compiler,omitempty"`
+
+	// Engines from package.json, e.g. the required node version.
+	Engines map[string]string `json:"engines,omitempty"`
+
+	// HasPWAPlugin is set when a Vite PWA plugin is installed,
+	// so the app registers a service worker.
+	HasPWAPlugin bool `json:"has_pwa_plugin,omitempty"`
+
+	// Frameworks lists every supported framework found, in
+	// detection order. More than one means the choice of
+	// PackageType is ambiguous.
+	Frameworks []string `json:"frameworks,omitempty"`
 }
 
 func (vc *ViteConfig) parsePackageJSON() (*PackageJSON, error) {
-	// If not set, try and find package.json
-	path := ""
-
-	if _, ok := vc.FS.(embed.FS); ok {
-		path = vc.JSProjectPath + "/"
+	projectFS, err := correctFS(vc.FS, vc.FSRoot, vc.JSProjectPath)
+	if err != nil {
+		return nil, err
 	}
 
-	buf, err := fs.ReadFile(vc.FS, path+"package.json")
+	buf, err := fs.ReadFile(projectFS, vc.packageJSONName())
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %w", ErrNoPackageJSON, err)
+	}
 
 	if err != nil {
 		return nil, err
@@ -52,19 +122,70 @@ func (vc *ViteConfig) parsePackageJSON() (*PackageJSON, error) {
 	err = json.Unmarshal(buf, &content)
 
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPackageJSON, err)
 	}
 
 	return &content, nil
 }
 
-func analyzePackageJSON(pkgJSON *PackageJSON) *JSAppParams {
-	semVer := regexp.MustCompile(`^[\^]*((\d+)\.\d+\.\d+)$`)
+// packageJSONName returns the package.json path to read,
+// relative to the JS project.
+func (vc *ViteConfig) packageJSONName() string {
+	if vc.PackageJSONName == "" {
+		return "package.json"
+	}
+
+	return path.Clean(strings.TrimPrefix(vc.PackageJSONName, "/"))
+}
+
+// versionProtocols are the dependency version prefixes that
+// point into a workspace rather than at a registry version.
+// Such dependencies still count for framework detection.
+var versionProtocols = []string{"workspace:", "catalog:", "link:", "file:"}
+
+// viteBuilders run Vite themselves, so projects using them
+// need not depend on vite directly.
+var viteBuilders = []string{"@angular/build", "@analogjs/vite-plugin-angular"}
+
+// hasViteBuilder reports whether package.json depends on one of
+// the viteBuilders.
+func hasViteBuilder(pkgJSON *PackageJSON) bool {
+	for _, builder := range viteBuilders {
+		if _, ok := pkgJSON.DevDependencies[builder]; ok {
+			return true
+		}
+
+		if _, ok := pkgJSON.Dependencies[builder]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// analyzePackageJSON works out the defaults for a package.json.
+// When several frameworks are found the first supported one wins,
+// unless platform names one of them, as an explicit Platform does.
+func analyzePackageJSON(pkgJSON *PackageJSON, platform string) *JSAppParams {
+	// Matches x.y.z after an optional ^, ~ or comparator, with
+	// any pre-release/build suffix ignored. For ranges such as
+	// ">=4.0.0 <6.0.0" the lower bound is used.
+	semVer := regexp.MustCompile(`^\s*(?:[\^~]|[<>]=?|=)?\s*v?((\d+)\.\d+\.\d+)(?:[-+][0-9A-Za-z.-]*)?(?:\s|$)`)
 
-	// parse for a ver; return the full version,
+	// parse for a ver; return the release triple,
 	// and the major version. Empty strings if
 	// the version does not fit our regexp.
 	getSemVer := func(verStr string) (string, string) {
+		// Workspace protocols (pnpm/yarn) only sometimes carry a
+		// version, as in "workspace:^3.2.0". Use it if present;
+		// "workspace:*", "catalog:" or a path just won't match.
+		for _, protocol := range versionProtocols {
+			if strings.HasPrefix(verStr, protocol) {
+				verStr = strings.TrimPrefix(verStr, protocol)
+				break
+			}
+		}
+
 		matches := semVer.FindStringSubmatch(verStr)
 
 		var major string
@@ -85,23 +206,56 @@ func analyzePackageJSON(pkgJSON *PackageJSON) *JSAppParams {
 		major, full := getSemVer(viteVers)
 		output.ViteMajorVer = major
 		output.ViteVersion = full
-	} else {
+	} else if !hasViteBuilder(pkgJSON) {
 		// Can't do anything with this package.json
 		return nil
 	}
 
+	output.Engines = pkgJSON.Engines
+
 	// TS?
 	_, ok := pkgJSON.DevDependencies["typescript"]
 	if ok {
 		output.HasTypeScript = true
 	}
 
+	// PWA plugin? It serves its service worker in dev too.
+	for _, plugin := range pwaPlugins {
+		if _, ok := pkgJSON.DevDependencies[plugin]; ok {
+			output.HasPWAPlugin = true
+		} else if _, ok := pkgJSON.Dependencies[plugin]; ok {
+			output.HasPWAPlugin = true
+		}
+	}
+
 	supported := []string{
 		"vue",
 		"react",
 		"preact",
 		"svelte", // devdep!
 		"lit",    // won't really support
+		"solid-js",
+		"astro",
+		"@builder.io/qwik",
+		"@angular/core",
+	}
+
+	// platform names that differ from the package name
+	platformNames := map[string]string{
+		"solid-js":         "solid",
+		"@builder.io/qwik": "qwik",
+		"@angular/core":    "angular",
+	}
+
+	// An explicit platform goes first, so the entry and versions
+	// reported are its own rather than another framework's.
+	if platform != "" {
+		for i, pkg := range supported {
+			if pkg == platform || platformNames[pkg] == platform {
+				supported = append([]string{pkg}, append(supported[:i:i], supported[i+1:]...)...)
+				break
+			}
+		}
 	}
 
 	var vers string
@@ -110,6 +264,12 @@ func analyzePackageJSON(pkgJSON *PackageJSON) *JSAppParams {
 			// special cased because svelte does not put
 			// any configuration into dependencies.
 			if sVer, ok := pkgJSON.DevDependencies["svelte"]; ok {
+				output.Frameworks = append(output.Frameworks, pkg)
+				if output.PackageType != "" {
+					// first framework found wins
+					continue
+				}
+
 				vers = sVer
 				major, full := getSemVer(vers)
 				output.PackageType = pkg
@@ -123,12 +283,21 @@ func analyzePackageJSON(pkgJSON *PackageJSON) *JSAppParams {
 				}
 
 				output.EntryPoint = entryPt
-
-				break
 			}
 		} else {
 			if vers, ok = pkgJSON.Dependencies[pkg]; ok {
-				output.PackageType = pkg
+				platform := pkg
+				if name, ok := platformNames[pkg]; ok {
+					platform = name
+				}
+
+				output.Frameworks = append(output.Frameworks, platform)
+				if output.PackageType != "" {
+					// first framework found wins
+					continue
+				}
+
+				output.PackageType = platform
 				major, full := getSemVer(vers)
 				output.MajorVer = major
 
@@ -138,10 +307,20 @@ func analyzePackageJSON(pkgJSON *PackageJSON) *JSAppParams {
 				switch pkg {
 				case "vue":
 					output.VueVersion = full
+					output.VueMajorVer = major
 					if output.HasTypeScript {
 						entryPt = "src/main.ts"
 					}
 
+					// Vue 2 templates compiled in the browser
+					// need the runtime+compiler build, which
+					// pairs with vue-template-compiler.
+					if _, ok := pkgJSON.DevDependencies["vue-template-compiler"]; ok {
+						output.HasVueTemplateCompiler = true
+					} else if _, ok := pkgJSON.Dependencies["vue-template-compiler"]; ok {
+						output.HasVueTemplateCompiler = true
+					}
+
 				case "react":
 					output.ReactVersion = full
 					if output.HasTypeScript {
@@ -158,16 +337,51 @@ func analyzePackageJSON(pkgJSON *PackageJSON) *JSAppParams {
 						entryPt = "src/main.jsx"
 					}
 
+				case "solid-js":
+					// solid uses index rather than main
+					output.SolidVersion = full
+					if output.HasTypeScript {
+						entryPt = "src/index.tsx"
+					} else {
+						entryPt = "src/index.jsx"
+					}
+
 				case "lit":
+					// lit's template has no main script; its
+					// index.html, which Vite serves as is,
+					// loads the element. SetDevelopmentDefaults
+					// looks for that element's source.
 					output.LitVersion = full
-					// we do not set entryPt;
-					// lit is just too weird.
+					entryPt = "index.html"
+					output.BestEffort = true
+
+				case "@builder.io/qwik":
+					// qwik resumes from server-rendered HTML
+					// rather than booting one client bundle,
+					// so tags for its root are best-effort.
+					output.QwikVersion = full
+					entryPt = "src/root.tsx"
+					output.BestEffort = true
+
+				case "@angular/core":
+					// Angular's Vite builders (@angular/build,
+					// analog's plugin) always use TypeScript.
+					// Their HMR works differently from the
+					// plugin-based frameworks: components are
+					// not swapped in place, so edits often
+					// reload the page.
+					output.AngularVersion = full
+					entryPt = "src/main.ts"
+
+				case "astro":
+					// astro pre-renders many HTML pages,
+					// with no single entry to point at
+					output.AstroVersion = full
 					entryPt = ""
 				}
 
 				// We know as much as we can...
 				output.EntryPoint = entryPt
-				break
 			}
 		}
 	}
@@ -190,6 +404,51 @@ func analyzePackageJSON(pkgJSON *PackageJSON) *JSAppParams {
 	return &output
 }
 
+// entryNames are the entry files tried, in order, for platforms
+// whose entry varies: Vue CLI-migrated projects often use
+// another name than main, and lit's template names the file
+// after its element.
+var entryNames = map[string][]string{
+	"vue": {"main", "app", "index", "entry-client"},
+	"lit": {"my-element", "index"},
+}
+
+// probeEntry points defaults.EntryPoint at the first of the
+// platform's entry candidates that exists. If none does, the
+// conventional entry is kept.
+func (vc *ViteConfig) probeEntry(defaults *JSAppParams) {
+	names, ok := entryNames[defaults.PackageType]
+	if !ok {
+		return
+	}
+
+	projectFS, err := correctFS(vc.FS, vc.FSRoot, vc.JSProjectPath)
+	if err != nil {
+		return
+	}
+
+	exts := []string{".js", ".ts"}
+	if defaults.HasTypeScript {
+		exts = []string{".ts", ".js"}
+	}
+
+	for _, name := range names {
+		for _, ext := range exts {
+			candidate := "src/" + name + ext
+
+			if _, err := fs.Stat(projectFS, candidate); err == nil {
+				if candidate != defaults.EntryPoint {
+					vc.logger().Debug("vitgo: using entry point", "platform", defaults.PackageType, "entry", candidate)
+				}
+
+				defaults.EntryPoint = candidate
+
+				return
+			}
+		}
+	}
+}
+
 func (vc *ViteConfig) getViteVersion() (string, error) {
 	// If it's set, use it.
 	if vc.ViteVersion != "" {
@@ -197,7 +456,7 @@ func (vc *ViteConfig) getViteVersion() (string, error) {
 	}
 
 	if vc.DevDefaults == nil {
-		return "", errors.New("not Vite project")
+		return "", ErrNotViteProject
 	}
 
 	vc.ViteVersion = vc.DevDefaults.ViteMajorVer
@@ -206,7 +465,38 @@ func (vc *ViteConfig) getViteVersion() (string, error) {
 
 }
 
+// defaultsState serializes the defaults setters of one config, so
+// a config whose defaults are computed lazily, on first request,
+// can be set up from several goroutines at once. It sits behind a
+// pointer because ViteConfig is copied by value.
+type defaultsState struct {
+	mu sync.Mutex
+}
+
+// defaultsStateMu only guards allocating a config's defaultsState;
+// the setters themselves run under the per-config lock.
+var defaultsStateMu sync.Mutex
+
+// lockDefaults locks the config's defaultsState, allocating it on
+// first use, and returns the unlock function.
+func (vc *ViteConfig) lockDefaults() func() {
+	defaultsStateMu.Lock()
+	if vc.defaults == nil {
+		vc.defaults = &defaultsState{}
+	}
+	state := vc.defaults
+	defaultsStateMu.Unlock()
+
+	state.mu.Lock()
+
+	return state.mu.Unlock
+}
+
 func (vc *ViteConfig) SetDevelopmentDefaults() error {
+	defer vc.lockDefaults()()
+
+	vc.ResolveEnvironment()
+
 	// Make sure we can find package.json:
 	if vc.JSProjectPath == "" {
 		vc.JSProjectPath = "frontend"
@@ -217,11 +507,18 @@ func (vc *ViteConfig) SetDevelopmentDefaults() error {
 		return err
 	}
 
-	defaults := analyzePackageJSON(pkgJSON)
+	defaults := analyzePackageJSON(pkgJSON, vc.Platform)
 	if defaults == nil {
-		return errors.New("invalid configuration")
+		return ErrNotViteProject
+	}
+
+	if len(defaults.Frameworks) > 1 && vc.StrictFramework && vc.Platform == "" {
+		return fmt.Errorf("%w: %s", ErrAmbiguousFramework, strings.Join(defaults.Frameworks, ", "))
 	}
 
+	vc.probeEntry(defaults)
+	vc.probeExportsEntry(pkgJSON, defaults)
+
 	vc.DevDefaults = defaults
 	version, err := vc.getViteVersion()
 
@@ -245,11 +542,24 @@ func (vc *ViteConfig) SetDevelopmentDefaults() error {
 		vc.URLPrefix = "/src/"
 	}
 
+	if vc.DevServerPort == "" && defaults.PackageType == "angular" && defaults.ViteVersion == "" {
+		// ng serve, which runs Vite itself
+		vc.DevServerPort = DEFAULT_PORT_ANGULAR
+	}
+
 	if vc.DevServerPort == "" {
-		if version == "2" {
+		switch version {
+		case "2":
 			vc.DevServerPort = DEFAULT_PORT_V2
-		} else {
+		case "3":
 			vc.DevServerPort = DEFAULT_PORT_V3
+		case "4":
+			vc.DevServerPort = DEFAULT_PORT_V4
+		case "5":
+			vc.DevServerPort = DEFAULT_PORT_V5
+		default:
+			// 6 and anything newer
+			vc.DevServerPort = DEFAULT_PORT_V6
 		}
 	}
 
@@ -262,10 +572,18 @@ func (vc *ViteConfig) SetDevelopmentDefaults() error {
 }
 
 func (vc *ViteConfig) SetProductionDefaults() error {
+	defer vc.lockDefaults()()
+
+	vc.ResolveEnvironment()
+
 	if vc.JSProjectPath == "" {
 		vc.JSProjectPath = "frontend"
 	}
 
+	if vc.AssetsPath == "" {
+		vc.AssetsPath = vc.viteConfigOutDir()
+	}
+
 	if vc.AssetsPath == "" {
 		vc.AssetsPath = "dist"
 	}
@@ -290,3 +608,51 @@ func (vc *ViteConfig) buildDevServerBaseURL() string {
 		vc.DevServerPort,
 	)
 }
+
+// buildHMRURL is buildDevServerBaseURL for the HMR websocket,
+// honoring HMRPort and HMRPath.
+func (vc *ViteConfig) buildHMRURL() string {
+	protocol := "http"
+	if vc.HTTPS {
+		protocol = "https"
+	}
+
+	return vc.hmrURL(protocol)
+}
+
+// buildHMRWebSocketURL is the ws:// or wss:// URL the browser
+// opens for HMR. HMRSecure, when set, decides the scheme over
+// HTTPS.
+func (vc *ViteConfig) buildHMRWebSocketURL() string {
+	secure := vc.HTTPS
+	if vc.HMRSecure != nil {
+		secure = *vc.HMRSecure
+	}
+
+	protocol := "ws"
+	if secure {
+		protocol = "wss"
+	}
+
+	return vc.hmrURL(protocol)
+}
+
+// hmrURL returns HMR's URL with the given scheme: its host and
+// port, and HMRPath when that is set.
+func (vc *ViteConfig) hmrURL(scheme string) string {
+	port := vc.HMRPort
+	if port == "" {
+		port = vc.DevServerPort
+	}
+
+	hmr := url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(vc.DevServerDomain, port),
+	}
+
+	if hmrPath := strings.Trim(vc.HMRPath, "/"); hmrPath != "" {
+		hmr.Path = "/" + hmrPath
+	}
+
+	return hmr.String()
+}
@@ -0,0 +1,249 @@
+package vitgo
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSetDevelopmentDefaultsConcurrent(t *testing.T) {
+	config := &ViteConfig{
+		FS: fstest.MapFS{
+			"frontend/package.json": {Data: []byte(`{
+				"dependencies": {"react": "^18.2.0"},
+				"devDependencies": {"vite": "^5.0.0"}
+			}`)},
+			"frontend/src/main.tsx": {Data: []byte("")},
+		},
+		Environment: "development",
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- config.SetDevelopmentDefaults()
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("SetDevelopmentDefaults: %v", err)
+		}
+	}
+
+	if config.Platform != "react" {
+		t.Errorf("Platform = %q, want react", config.Platform)
+	}
+
+	if config.DevServerPort != DEFAULT_PORT_V5 {
+		t.Errorf("DevServerPort = %q, want %q", config.DevServerPort, DEFAULT_PORT_V5)
+	}
+}
+
+func TestDefaultsStateIsPerConfig(t *testing.T) {
+	a, b := &ViteConfig{}, &ViteConfig{}
+
+	unlock := a.lockDefaults()
+	defer unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.lockDefaults()()
+		close(done)
+	}()
+
+	<-done
+}
+
+// reactVuePackageJSON is a project migrating from Vue to React.
+var reactVuePackageJSON = []byte(`{
+	"dependencies": {"react": "^18.2.0", "vue": "^3.4.0"},
+	"devDependencies": {"vite": "^5.0.0"}
+}`)
+
+func TestMultipleFrameworksFirstWins(t *testing.T) {
+	config := &ViteConfig{
+		FS:          fstest.MapFS{"frontend/package.json": {Data: reactVuePackageJSON}},
+		Environment: "development",
+	}
+
+	if err := config.SetDevelopmentDefaults(); err != nil {
+		t.Fatalf("SetDevelopmentDefaults: %v", err)
+	}
+
+	if config.Platform != "vue" {
+		t.Errorf("Platform = %q, want vue", config.Platform)
+	}
+
+	if got := strings.Join(config.DevDefaults.Frameworks, ","); got != "vue,react" {
+		t.Errorf("Frameworks = %q, want vue,react", got)
+	}
+}
+
+func TestMultipleFrameworksStrict(t *testing.T) {
+	config := &ViteConfig{
+		FS:              fstest.MapFS{"frontend/package.json": {Data: reactVuePackageJSON}},
+		Environment:     "development",
+		StrictFramework: true,
+	}
+
+	err := config.SetDevelopmentDefaults()
+	if !errors.Is(err, ErrAmbiguousFramework) {
+		t.Fatalf("SetDevelopmentDefaults = %v, want ErrAmbiguousFramework", err)
+	}
+
+	config.Platform = "react"
+
+	if err := config.SetDevelopmentDefaults(); err != nil {
+		t.Fatalf("SetDevelopmentDefaults with Platform: %v", err)
+	}
+
+	if config.EntryPoint != "src/main.jsx" {
+		t.Errorf("EntryPoint = %q, want src/main.jsx", config.EntryPoint)
+	}
+}
+
+func TestVue2Detection(t *testing.T) {
+	config, err := NewConfigFromFS(fstest.MapFS{
+		"package.json": {Data: []byte(`{
+			"dependencies": {"vue": "^2.7.14"},
+			"devDependencies": {"vite": "^4.5.0", "vue-template-compiler": "^2.7.14", "typescript": "^5.2.0"}
+		}`)},
+		"src/app.ts": {Data: []byte("")},
+	}, "")
+	if err != nil {
+		t.Fatalf("NewConfigFromFS: %v", err)
+	}
+
+	defaults := config.DevDefaults
+
+	if defaults.VueMajorVer != "2" {
+		t.Errorf("VueMajorVer = %q, want 2", defaults.VueMajorVer)
+	}
+
+	if defaults.VueVersion != "2.7.14" {
+		t.Errorf("VueVersion = %q, want 2.7.14", defaults.VueVersion)
+	}
+
+	if !defaults.HasVueTemplateCompiler {
+		t.Error("HasVueTemplateCompiler = false, want true")
+	}
+
+	// no src/main.ts, so the next candidate is used
+	if config.EntryPoint != "src/app.ts" {
+		t.Errorf("EntryPoint = %q, want src/app.ts", config.EntryPoint)
+	}
+
+	if config.DevServerPort != DEFAULT_PORT_V4 {
+		t.Errorf("DevServerPort = %q, want %q", config.DevServerPort, DEFAULT_PORT_V4)
+	}
+}
+
+func TestHMRURLs(t *testing.T) {
+	secure, insecure := true, false
+
+	tests := []struct {
+		name     string
+		config   ViteConfig
+		wantHTTP string
+		wantWS   string
+	}{
+		{"defaults", ViteConfig{}, "http://localhost:5173", "ws://localhost:5173"},
+		{"https", ViteConfig{HTTPS: true}, "https://localhost:5173", "wss://localhost:5173"},
+		{"port", ViteConfig{HMRPort: "24678"}, "http://localhost:24678", "ws://localhost:24678"},
+		{"path", ViteConfig{HMRPath: "hmr"}, "http://localhost:5173/hmr", "ws://localhost:5173/hmr"},
+		{"slashed path", ViteConfig{HMRPath: "/hmr/"}, "http://localhost:5173/hmr", "ws://localhost:5173/hmr"},
+		{"nested path", ViteConfig{HMRPath: "/_vite/hmr"}, "http://localhost:5173/_vite/hmr", "ws://localhost:5173/_vite/hmr"},
+		{"root path", ViteConfig{HMRPath: "/"}, "http://localhost:5173", "ws://localhost:5173"},
+		{"secure over http", ViteConfig{HMRSecure: &secure}, "http://localhost:5173", "wss://localhost:5173"},
+		{"insecure over https", ViteConfig{HTTPS: true, HMRSecure: &insecure}, "https://localhost:5173", "ws://localhost:5173"},
+		{"ipv6", ViteConfig{DevServerDomain: "::1"}, "http://[::1]:5173", "ws://[::1]:5173"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			if config.DevServerDomain == "" {
+				config.DevServerDomain = "localhost"
+			}
+			config.DevServerPort = DEFAULT_PORT_V5
+
+			if got := config.buildHMRURL(); got != tt.wantHTTP {
+				t.Errorf("buildHMRURL() = %q, want %q", got, tt.wantHTTP)
+			}
+
+			if got := config.buildHMRWebSocketURL(); got != tt.wantWS {
+				t.Errorf("buildHMRWebSocketURL() = %q, want %q", got, tt.wantWS)
+			}
+		})
+	}
+}
+
+func TestAnalyzePWAPlugin(t *testing.T) {
+	pkg := &PackageJSON{DevDependencies: map[string]string{"vite": "^5.0.0", "vite-plugin-pwa": "^0.19.0"}}
+
+	if defaults := analyzePackageJSON(pkg, ""); defaults == nil || !defaults.HasPWAPlugin {
+		t.Errorf("HasPWAPlugin not set for %v", pkg.DevDependencies)
+	}
+
+	delete(pkg.DevDependencies, "vite-plugin-pwa")
+
+	if defaults := analyzePackageJSON(pkg, ""); defaults == nil || defaults.HasPWAPlugin {
+		t.Errorf("HasPWAPlugin set without a PWA plugin")
+	}
+}
+
+func TestParsePackageJSONImports(t *testing.T) {
+	parse := func(contents string) (*PackageJSON, error) {
+		config := &ViteConfig{
+			FS:            fstest.MapFS{"frontend/package.json": {Data: []byte(contents)}},
+			JSProjectPath: "frontend",
+		}
+
+		return config.parsePackageJSON()
+	}
+
+	pkgJSON, err := parse(`{
+		"name": "app",
+		"imports": {
+			"#utils": "./src/utils.ts",
+			"#config": {"node": "./config.node.js", "browser": "./config.browser.js"},
+			"#native": {"node": "./native.node.js"}
+		}
+	}`)
+	if err != nil {
+		t.Fatalf("parsePackageJSON: %v", err)
+	}
+
+	if pkgJSON.Name != "app" {
+		t.Errorf("Name = %q, want app", pkgJSON.Name)
+	}
+
+	want := map[string]string{"#utils": "./src/utils.ts", "#config": "./config.browser.js"}
+	if len(pkgJSON.Imports) != len(want) {
+		t.Errorf("Imports = %v, want %v", pkgJSON.Imports, want)
+	}
+
+	for name, target := range want {
+		if pkgJSON.Imports[name] != target {
+			t.Errorf("Imports[%q] = %q, want %q", name, pkgJSON.Imports[name], target)
+		}
+	}
+
+	if pkgJSON, err := parse(`{"name": "app"}`); err != nil || pkgJSON.Imports != nil {
+		t.Errorf("no imports: Imports = %v, err = %v", pkgJSON.Imports, err)
+	}
+
+	if _, err := parse(`{"name": "app", "imports": ["./src/utils.ts"]}`); !errors.Is(err, ErrInvalidPackageJSON) {
+		t.Errorf("imports not an object: err = %v, want ErrInvalidPackageJSON", err)
+	}
+}
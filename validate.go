@@ -0,0 +1,76 @@
+package vitgo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// Validate checks the config for problems NewVitGo would only
+// run into later, or not at all, and returns all of them joined
+// together, or nil. An EntryPoint missing from the FS is only
+// logged as a warning, since Vite may generate it.
+func (vc *ViteConfig) Validate() error {
+	var errs []error
+
+	switch vc.Environment {
+	case "", "development", "production":
+	default:
+		errs = append(errs, fmt.Errorf("environment %q is not development or production", vc.Environment))
+	}
+
+	if vc.HTTPS && vc.DevServerDomain == "" {
+		errs = append(errs, errors.New("HTTPS is set but DevServerDomain is empty"))
+	}
+
+	if _, err := validateLoadStrategy(vc.LoadStrategy); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := validateRootBehavior(vc.RootBehavior); err != nil {
+		errs = append(errs, err)
+	}
+
+	if vc.FS == nil {
+		errs = append(errs, errors.New("FS is not set"))
+		return errors.Join(errs...)
+	}
+
+	projectFS, err := correctFS(vc.FS, vc.FSRoot, vc.JSProjectPath)
+	if err != nil {
+		errs = append(errs, err)
+		return errors.Join(errs...)
+	}
+
+	if !vc.hasProject(projectFS) {
+		errs = append(errs, fmt.Errorf("JS project %q not found in FS", vc.JSProjectPath))
+		return errors.Join(errs...)
+	}
+
+	if vc.EntryPoint != "" && vc.Environment != "production" {
+		if _, err := fs.Stat(projectFS, path.Clean(vc.EntryPoint)); err != nil {
+			vc.logger().Warn("vitgo: entry point not found in FS", "entry", vc.EntryPoint)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// hasProject reports whether projectFS looks like the JS
+// project: correctFS leaves the FS alone when JSProjectPath is
+// missing, so check for something the project would contain.
+func (vc *ViteConfig) hasProject(projectFS fs.FS) bool {
+	candidates := []string{vc.packageJSONName()}
+	if vc.AssetsPath != "" {
+		candidates = append(candidates, vc.AssetsPath)
+	}
+
+	for _, candidate := range candidates {
+		if _, err := fs.Stat(projectFS, candidate); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
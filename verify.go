@@ -0,0 +1,69 @@
+package vitgo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Verify checks, in production, that every entry in the manifest
+// can be served: its file, its stylesheets and every chunk it
+// imports, with their stylesheets, must exist in DistFS. All
+// missing files are reported together. Run it in CI or at
+// deploy time to catch a broken build before users do.
+func (vg *VitGo) Verify() error {
+	if vg.Environment != "production" {
+		return nil
+	}
+
+	manifest, err := vg.ParseManifest()
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name, entry := range manifest {
+		if entry.IsEntry {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	var errs []error
+	checked := map[string]bool{}
+
+	check := func(entry, file string) {
+		if file == "" || isAbsoluteURL(file) || checked[file] {
+			return
+		}
+
+		checked[file] = true
+
+		name := path.Join(vg.AssetPath, strings.TrimPrefix(file, "/"))
+		if _, err := fs.Stat(vg.DistFS, name); err != nil {
+			errs = append(errs, fmt.Errorf("entry %s: %w", entry, err))
+		}
+	}
+
+	for _, name := range names {
+		entry := manifest[name]
+
+		check(name, entry.File)
+		for _, css := range entry.CSS {
+			check(name, css)
+		}
+
+		for _, chunk := range manifest.ImportedChunks(name) {
+			check(name, chunk.File)
+			for _, css := range chunk.CSS {
+				check(name, css)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
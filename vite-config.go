@@ -0,0 +1,111 @@
+package vitgo
+
+import (
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// viteConfigFiles are the Vite config file names looked at for
+// build.outDir, in Vite's own order of preference.
+var viteConfigFiles = []string{
+	"vite.config.js",
+	"vite.config.mjs",
+	"vite.config.ts",
+	"vite.config.cjs",
+	"vite.config.mts",
+	"vite.config.cts",
+}
+
+// outDirOption matches a literal outDir string. Template
+// literals with ${...} are computed, so are not matched.
+var outDirOption = regexp.MustCompile("(?m)^[^/\\n]*\\boutDir\\s*:\\s*(?:'([^'\\n]*)'|\"([^\"\\n]*)\"|`([^`$\\n]*)`)")
+
+// viteConfigOutDir returns build.outDir from the project's Vite
+// config when it is a plain string literal inside FS, or "" if
+// there is none or it can't be worked out without running the
+// config. The result is relative to the project, so it may start
+// with ../ (see resolveInFS).
+func (vc *ViteConfig) viteConfigOutDir() string {
+	if vc.FS == nil {
+		return ""
+	}
+
+	projectFS, err := correctFS(vc.FS, vc.FSRoot, vc.JSProjectPath)
+	if err != nil {
+		return ""
+	}
+
+	for _, name := range viteConfigFiles {
+		buf, err := fs.ReadFile(projectFS, name)
+		if err != nil {
+			continue
+		}
+
+		matches := outDirOption.FindAllSubmatch(buf, -1)
+		if len(matches) != 1 {
+			// none, or several we can't choose between
+			return ""
+		}
+
+		raw := string(matches[0][1]) + string(matches[0][2]) + string(matches[0][3])
+		outDir := fsPath(raw)
+
+		if outDir == "" || outDir == "." {
+			return ""
+		}
+
+		if path.IsAbs(raw) {
+			vc.logger().Warn("vitgo: outDir is an absolute path; set AssetsPath", "out_dir", raw)
+			return ""
+		}
+
+		if _, ok := vc.resolveInFS(outDir); !ok {
+			vc.logger().Warn("vitgo: outDir is outside FS; set AssetsPath", "out_dir", outDir)
+			return ""
+		}
+
+		return outDir
+	}
+
+	return ""
+}
+
+// leavesProject reports whether a cleaned, project-relative path
+// points above the project.
+func leavesProject(p string) bool {
+	return p == ".." || strings.HasPrefix(p, "../")
+}
+
+// projectDir returns the JS project's directory inside FS, as
+// correctFS picks it, or "." when FS already points at it.
+func (vc *ViteConfig) projectDir() string {
+	if root := fsPath(vc.FSRoot); root != "" {
+		return root
+	}
+
+	projectPath := fsPath(vc.JSProjectPath)
+	if projectPath == "" || projectPath == "." || vc.FS == nil {
+		return "."
+	}
+
+	if info, err := fs.Stat(vc.FS, projectPath); err == nil && info.IsDir() {
+		return projectPath
+	}
+
+	return "."
+}
+
+// resolveInFS resolves a project-relative path against the
+// project's directory in FS, so ../server/static with the project
+// at web/frontend gives web/server/static. ok is false when the
+// path leaves FS altogether.
+func (vc *ViteConfig) resolveInFS(rel string) (string, bool) {
+	resolved := path.Join(vc.projectDir(), rel)
+	if leavesProject(resolved) {
+		return "", false
+	}
+
+	return resolved, true
+}
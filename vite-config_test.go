@@ -0,0 +1,97 @@
+package vitgo
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestViteConfigOutDir(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"defineConfig", `export default defineConfig({ build: { outDir: 'build' } })`, "build"},
+		{"multi-line", "export default defineConfig({\n  plugins: [react()],\n  build: {\n    outDir: \"public/dist/\",\n  },\n})", "public/dist"},
+		{"template literal", "export default defineConfig({ build: { outDir: `out` } })", "out"},
+		{"computed", "export default defineConfig({ build: { outDir: `${root}/out` } })", ""},
+		{"commented out", "export default defineConfig({\n  build: {\n    // outDir: 'old',\n  },\n})", ""},
+		{"ambiguous", "export default defineConfig(({ mode }) => ({\n  build: mode === 'lib' ? { outDir: 'lib' } : {\n    outDir: 'app' },\n}))", ""},
+		{"parent in FS", `export default defineConfig({ build: { outDir: '../server/static' } })`, "../server/static"},
+		{"parent outside FS", `export default defineConfig({ build: { outDir: '../../../elsewhere' } })`, ""},
+		{"absolute", `export default defineConfig({ build: { outDir: '/srv/static' } })`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &ViteConfig{
+				FS:             fstest.MapFS{"web/frontend/vite.config.ts": {Data: []byte(tt.config)}},
+				FSRoot:         "web/frontend",
+				DisableLogging: true,
+			}
+
+			if got := config.viteConfigOutDir(); got != tt.want {
+				t.Errorf("viteConfigOutDir() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutDirAboveProject(t *testing.T) {
+	fsys := fstest.MapFS{
+		"web/frontend/package.json":               {Data: []byte(`{"devDependencies": {"vite": "^5.0.0"}}`)},
+		"web/frontend/vite.config.ts":             {Data: []byte(`export default defineConfig({ build: { outDir: '../server/static' } })`)},
+		"web/server/static/.vite/manifest.json":   {Data: []byte(testManifest)},
+		"web/server/static/assets/main-4f2a1b.js": {Data: []byte("console.log('main')")},
+		"web/server/main.go":                      {Data: []byte("package main")},
+	}
+
+	for name, config := range map[string]*ViteConfig{
+		"FSRoot":        {FS: fsys, FSRoot: "web/frontend"},
+		"JSProjectPath": {FS: fsys, JSProjectPath: "web/frontend"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config.Environment = "production"
+			config.Platform = "react"
+			config.DisableLogging = true
+
+			vg, err := NewVitGo(config)
+			if err != nil {
+				t.Fatalf("NewVitGo: %v", err)
+			}
+
+			if vg.AssetPath != "web/server/static" {
+				t.Errorf("AssetPath = %q, want web/server/static", vg.AssetPath)
+			}
+
+			handler := fileServer(t, vg)
+
+			rec := serve(handler, http.MethodGet, "/assets/main-4f2a1b.js")
+			if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "console.log") {
+				t.Errorf("GET /assets/main-4f2a1b.js = %d %q", rec.Code, rec.Body.String())
+			}
+
+			// only the build is served, not its siblings
+			if rec := serve(handler, http.MethodGet, "/../main.go"); rec.Code != http.StatusNotFound {
+				t.Errorf("GET /../main.go = %d, want 404", rec.Code)
+			}
+		})
+	}
+}
+
+func TestAssetsPathOutsideFS(t *testing.T) {
+	_, err := NewVitGo(&ViteConfig{
+		FS:             prodFS(),
+		FSRoot:         ".",
+		AssetsPath:     "../dist",
+		Environment:    "production",
+		Platform:       "react",
+		DisableLogging: true,
+	})
+	if !errors.Is(err, ErrAssetsOutsideFS) {
+		t.Fatalf("NewVitGo = %v, want ErrAssetsOutsideFS", err)
+	}
+}
@@ -1,15 +1,50 @@
 package vitgo
 
 import (
-	"embed"
 	"errors"
+	"fmt"
 	"io/fs"
+	"log/slog"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
 	DEFAULT_VITE_VERSION = "3"
 	DEFAULT_PORT_V2      = "3000"
 	DEFAULT_PORT_V3      = "5173"
+	DEFAULT_PORT_V4      = "5173"
+	DEFAULT_PORT_V5      = "5173"
+	DEFAULT_PORT_V6      = "5173"
+	DEFAULT_PORT_ANGULAR = "4200"
+)
+
+// Where the manifest lives, relative to the assets path. Vite 5
+// moved it into .vite/.
+const (
+	MANIFEST_FILE_V2 = "manifest.json"
+	MANIFEST_FILE_V5 = ".vite/manifest.json"
+)
+
+// Root behaviors for requests to / on the file server.
+const (
+	ROOT_INDEX    = "index"
+	ROOT_404      = "404"
+	ROOT_REDIRECT = "redirect:"
+)
+
+// Loading strategies for the entry script tag. The entry is an
+// ES module, so it always gets type="module", which the browser
+// defers by itself: LOAD_DEFER is the same as LOAD_MODULE, and
+// LOAD_ASYNC the same as LOAD_MODULE_ASYNC.
+const (
+	LOAD_MODULE       = "module"
+	LOAD_MODULE_ASYNC = "module-async"
+	LOAD_DEFER        = "defer"
+	LOAD_ASYNC        = "async"
 )
 
 // type ViteConfig passes info needed to generate the library's
@@ -18,22 +53,38 @@ type ViteConfig struct {
 	// FS is the filesystem where assets can be loaded.
 	FS fs.FS
 
+	// FSRoot is the path of the JS project inside FS, or "."
+	// if FS already points at it. When empty, JSProjectPath is
+	// looked up inside FS and used if present.
+	FSRoot string
+
 	// DevDefaults is best guess for defaults
 	DevDefaults *JSAppParams `json:"-"`
 
+	// defaults serializes SetDevelopmentDefaults and
+	// SetProductionDefaults on this config.
+	defaults *defaultsState
+
 	// Environment (development|production). In development mode,
 	// the package sets up hot reloading. In production, the
 	// package builds the Vue/Vuex production files and embeds them
-	// in the Go app.
+	// in the Go app. When empty, it is read from VITGO_ENV,
+	// APP_ENV or GO_ENV; see ResolveEnvironment.
 	Environment string
 
 	// JSProjectPath is where your JS project is relative to the
 	// root of your project. Default: frontend
 	JSProjectPath string
 
-	//AssetsPath relative to the JSProjectPath. Empty for dev, dist for prod
+	//AssetsPath relative to the JSProjectPath. Empty for dev, dist for prod.
+	// A path above the project, such as ../server/static, is resolved
+	// against the project's directory in FS.
 	AssetsPath string
 
+	// PackageJSONName is the package.json to read, relative to
+	// the JSProjectPath. Default is package.json.
+	PackageJSONName string
+
 	// "2" or "3". If not set, we try to guess by looking
 	// at package.json
 	ViteVersion string
@@ -50,9 +101,33 @@ type ViteConfig struct {
 	// Default is false.
 	HTTPS bool
 
+	// DevClientURL is the full @vite/client URL, for dev
+	// servers behind a proxy or on a remote host (Docker,
+	// Gitpod...). Its origin is also used for HMR. Default is
+	// derived from DevServerDomain and DevServerPort.
+	DevClientURL string
+
+	// HMRPort and HMRPath mirror Vite's server.hmr.port and
+	// server.hmr.path, for HMR served apart from the dev server,
+	// e.g. on another port exposed by a container. Defaults are
+	// DevServerPort and /.
+	HMRPort string
+	HMRPath string
+
+	// HMRSecure forces the HMR websocket's scheme: wss when
+	// true, ws when false. Set it to true behind a proxy that
+	// terminates TLS but talks plain HTTP to Vite. Default
+	// follows HTTPS.
+	HMRSecure *bool
+
 	// URLPrefix (/assets/ for prod, /src/ for dev)
 	URLPrefix string
 
+	// BasePath mirrors Vite's base option: a subpath such as
+	// /app/, or a CDN URL, production assets are served from.
+	// Default is the server root.
+	BasePath string
+
 	// DevServer is the URL to use for the Vite dev server.
 	// Default is "http://localhost:3000".
 	// DevServer string
@@ -61,9 +136,84 @@ type ViteConfig struct {
 	// Default is "vue"
 	Platform string
 
+	// Frameworks lists every framework used on the page, for
+	// micro-frontends mixing e.g. react and vue islands. Each
+	// one's dev preamble is injected. Default is just Platform.
+	Frameworks []string
+
+	// StrictFramework makes SetDevelopmentDefaults fail when
+	// package.json has several frameworks and Platform is not
+	// set. Otherwise the first framework detected is used. A
+	// Platform naming one of them picks its entry point.
+	StrictFramework bool
+
 	// Entry point: as configured in vite.config.js. Typically
 	// src/main.js or src/main.ts.
 	EntryPoint string
+
+	// EntryPoints lists every entry when one Vite config builds
+	// several (e.g. index.html and admin.html). Each can be
+	// rendered by its file name without extension, as in
+	// RenderTags "admin". EntryPoint defaults to the first.
+	EntryPoints []string
+
+	// Inputs maps names to source paths, mirroring Vite's
+	// build.rollupOptions.input, so RenderTags(name) can
+	// render a specific entry.
+	Inputs map[string]string
+
+	// PagesRoot is where a multi-page app's html files live,
+	// relative to the project. Default is the project root.
+	PagesRoot string
+
+	// LoadStrategy (module|module-async|defer|async) controls how
+	// the entry script tag is loaded. Default is "module"; defer
+	// and async keep type="module" (see LOAD_DEFER).
+	LoadStrategy string
+
+	// WatchPackageJSON re-derives DevDefaults, Platform and
+	// EntryPoint in development when package.json's modtime
+	// changes. Values you set explicitly are kept.
+	WatchPackageJSON bool
+
+	// SSRNoClient is for server-only Vite builds with no client
+	// entry: a missing entry is not an error, and the renderers
+	// emit no client script, only CSS and preloads. Assets are
+	// still served as usual.
+	SSRNoClient bool
+
+	// TurboTrack adds data-turbo-track="reload" to production
+	// script and stylesheet tags, for Turbo/Hotwire navigation.
+	TurboTrack bool
+
+	// RootBehavior (index|404|redirect:<path>) is what the file
+	// server does for /. Default is "index".
+	RootBehavior string
+
+	// MetaSidecarPath points at a JSON file, relative to the
+	// JSProjectPath, with precomputed compression and SRI data.
+	MetaSidecarPath string
+
+	// ManifestFilename overrides Vite's build.manifest name,
+	// relative to AssetsPath. When empty .vite/manifest.json and
+	// then manifest.json are tried.
+	ManifestFilename string
+
+	// LenientJSON lets ReadJSONConfig accept comments and
+	// trailing commas. package.json is always strict.
+	LenientJSON bool
+
+	// LogStartupConfig logs a single line summarizing the
+	// resolved configuration when NewVitGo finishes.
+	LogStartupConfig bool
+
+	// DisableLogging silences all of the package's logging,
+	// including LogStartupConfig.
+	DisableLogging bool
+
+	// Logger receives the package's logging. Default is
+	// slog.Default().
+	Logger *slog.Logger
 }
 
 // type VitGo summarizes a manifest file, and points to the assets.
@@ -80,6 +230,14 @@ type VitGo struct {
 	// Default is http://localhost:5173
 	BaseURL string
 
+	// HMRURL is where Vite's HMR websocket listens, as an
+	// http(s) URL, in development.
+	HMRURL string
+
+	// HMRWebSocketURL is the ws(s) URL the browser opens for
+	// HMR, in development.
+	HMRWebSocketURL string
+
 	// JS Dependencies / Vendor libs
 	Imports []string
 
@@ -89,6 +247,9 @@ type VitGo struct {
 	// Target JS Platform
 	Platform string
 
+	// Frameworks active on the page (see ViteConfig.Frameworks)
+	Frameworks []string
+
 	// A file system or embed that points to the Vue/Vite dist
 	// directory (production) or the javascript src directory
 	// (development)
@@ -103,49 +264,462 @@ type VitGo struct {
 	// AssetPath is the relative path from the JSDirectory.
 	AssetPath string
 
+	// DevClientURL overrides the @vite/client URL (see
+	// ViteConfig).
+	DevClientURL string
+
+	// URLPrefix assets are served under (/assets/ in production).
+	URLPrefix string
+
+	// BasePath production asset URLs start with (see ViteConfig).
+	BasePath string
+
+	// LoadStrategy for the entry script tag.
+	LoadStrategy string
+
+	// Inputs maps names to source paths (see ViteConfig.Inputs).
+	Inputs map[string]string
+
+	// inputs holds the resolved assets of each named input.
+	inputs map[string]*VitGo
+
+	// manifest is the parsed manifest (production only).
+	manifest Manifest
+
+	// manifestFile is the manifest's path in AssetPath.
+	manifestFile string
+
+	// integrity holds SRI values from the manifest, by file.
+	integrity map[string]string
+
+	// manifestCache caches what ParseManifest reads when NewVitGo
+	// loaded no manifest.
+	manifestCache *manifestCache
+
+	// manifestWatch holds the manifest reloaded by
+	// WatchManifest, in production.
+	manifestWatch *manifestWatch
+
+	// CaseNormalization (redirect|serve) maps asset requests
+	// that only match a manifest file when ignoring case to
+	// that file, by 301 or by serving it directly. Off if empty.
+	CaseNormalization string
+
+	// caseIndex maps lowercased manifest files to real names.
+	caseIndex map[string]string
+
+	// watch tracks package.json (see WatchPackageJSON).
+	watch *packageWatch
+
+	// nonce is added to rendered tags (see WithNonce).
+	nonce string
+
+	// devProxy tracks DevProxyHandler's upstream connections
+	// for CloseDevProxy.
+	devProxy *devProxyTracker
+
+	// config is a copy of the resolved ViteConfig, served at
+	// CONFIG_DUMP_PATH when Debug is set.
+	config *ViteConfig
+
+	// PagesRoot is where a multi-page app's html files live,
+	// relative to the project (see PageEntry).
+	PagesRoot string
+
+	// SSRNoClient skips the client script (see ViteConfig).
+	SSRNoClient bool
+
+	// TurboTrack adds Turbo tracking attributes in production.
+	TurboTrack bool
+
+	// RootBehavior for requests to / (see ViteConfig).
+	RootBehavior string
+
+	// Sidecar holds precomputed compression and SRI data.
+	Sidecar Sidecar
+
+	// RedirectHTTPS makes StrictTransport redirect plain-HTTP
+	// requests to HTTPS with a 308.
+	RedirectHTTPS bool
+
+	// HSTS makes StrictTransport set Strict-Transport-Security.
+	HSTS bool
+
+	// HSTSMaxAge in seconds. Default is DEFAULT_HSTS_MAX_AGE.
+	HSTSMaxAge int
+
+	// IsolateCrossOrigin sets the headers SharedArrayBuffer
+	// apps need: COOP/COEP on documents, COEP on scripts (for
+	// workers) and CORP on every asset.
+	IsolateCrossOrigin bool
+
+	// CanonicalHost, when set, makes the package's handlers
+	// 301 requests for any other host to this one.
+	CanonicalHost string
+
+	// HealthPaths skip the CanonicalHost redirect.
+	// Default is DefaultHealthPaths.
+	HealthPaths []string
+
+	// CSPDirectives filled in by SuggestedCSP.
+	// Default is DefaultCSPDirectives.
+	CSPDirectives []string
+
 	// Debug mode
 	Debug bool
+
+	// DisableLogging silences all of the package's logging.
+	DisableLogging bool
+
+	// Logger receives the package's logging. Default is
+	// slog.Default().
+	Logger *slog.Logger
+
+	// VerifyOnServe checks each served file against its
+	// integrity hash (sidecar or manifest), answering 500 on a
+	// mismatch. Costs a hash per request, so it is opt-in.
+	VerifyOnServe bool
+
+	// CSSInlineThreshold inlines CSS files smaller than this
+	// many bytes in a <style> tag instead of linking them.
+	// Default is 0: always link.
+	CSSInlineThreshold int
+
+	// MaxInlineOpBytes caps the size of files the package reads
+	// into memory or hashes per request. 0 means no limit.
+	MaxInlineOpBytes int64
+
+	// DevServerCommand runs the dev server for StartDevServer.
+	// Default is package.json's dev script.
+	DevServerCommand []string
+
+	// DevServerDir is the directory StartDevServer runs in,
+	// relative to the process's working directory. Default is
+	// JSProjectPath.
+	DevServerDir string
+
+	// devAddress holds the address StartDevServer found.
+	devAddress *devAddress
+
+	// FriendlyDevErrors makes DevProxyHandler answer with an
+	// explanatory HTML page, rather than a bare 502, when the
+	// dev server is down.
+	FriendlyDevErrors bool
+
+	// PreambleProvider supplies the preamble.js the file server
+	// serves, e.g. one matching a newer @vitejs/plugin-react.
+	// Default is EmbeddedPreamble.
+	PreambleProvider func() ([]byte, error)
+
+	// HMRPingInterval is how often DevProxyHandler pings the
+	// browser on an idle HMR websocket, so proxies and load
+	// balancers in between do not drop it. Default is 30s; a
+	// negative value turns pings off.
+	HMRPingInterval time.Duration
+
+	// EnableSRI adds integrity attributes to production script,
+	// modulepreload and stylesheet tags: the sidecar or manifest
+	// hash if there is one, else a SHA-384 of the file, computed
+	// once per process.
+	EnableSRI bool
+
+	// sriCache holds the hashes computed for EnableSRI.
+	sriCache *sriCache
+
+	// Crossorigin forces the crossorigin attribute on (true) or
+	// off (false) for production scripts and modulepreloads.
+	// Default (nil): scripts always have it, preloads only with
+	// a CDN BasePath.
+	Crossorigin *bool
+
+	// ContentTypes overrides the Content-Type served for file
+	// extensions (e.g. ".mjs"), on top of DefaultContentTypes.
+	ContentTypes map[string]string
+
+	// MaxPreloads caps the modulepreload links RenderTags
+	// emits, keeping the entry's direct imports first. CSS is
+	// never capped. 0 means no limit.
+	MaxPreloads int
+
+	// AllowedOrigins lets pages on these origins (or any, with
+	// "*") load assets cross-origin, e.g. a widget embedded in
+	// another site during development. Empty sends no CORS
+	// headers.
+	AllowedOrigins []string
+
+	// SPAFallback serves index.html, in production, for paths
+	// that are not files, so client-side routes such as
+	// /dashboard/settings load the app. Missing assets still 404.
+	SPAFallback bool
+
+	// Custom404 serves the build's 404.html, with a 404 status,
+	// for missing files in production.
+	Custom404 bool
+
+	// RequestIDHeader is the header FileServer reads a request
+	// ID from, and echoes it back in, for the request log.
+	// Requests without one get a random ID. Default is
+	// X-Request-ID.
+	RequestIDHeader string
+
+	// ServeSourceMaps lets FileServer serve .map files. NewVitGo
+	// turns it on in development only, so production does not
+	// expose the original sources even when Vite emitted maps.
+	ServeSourceMaps bool
+
+	// CompressOnTheFly makes FileServer gzip text, JavaScript,
+	// JSON and SVG files of 1KiB or more in production, for
+	// clients that accept gzip, when the build ships no
+	// precompressed sibling.
+	CompressOnTheFly bool
+
+	// siblingCache holds which precompressed siblings decoded
+	// cleanly.
+	siblingCache *siblingCache
+
+	// etagCache holds the ETags hashed for FileServer.
+	etagCache *etagCache
+
+	// DisableCacheHeaders stops FileServer from setting
+	// Cache-Control: in production, for CDNs with their own
+	// rules, and the no-store on HTML in development.
+	DisableCacheHeaders bool
+
+	// ManifestPublic lets FileServer serve manifest.json.
+	// Default is false: requests for it get a 404.
+	ManifestPublic bool
 }
 
-// ParseManifest imports and parses a manifest returning a vgo object.
+// ParseManifest imports and parses a manifest returning a vgo
+// object for its first entry.
 func ParseManifest(contents []byte) (*VitGo, error) {
-	var testRslt manifestTarget
-	vgo, err := testRslt.parseWithoutReflection(contents)
+	manifest, err := parseManifest(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest.mainEntry(manifestKeys(contents))
+}
+
+// DefaultManifestFiles are tried in order, relative to the
+// assets path, when ViteConfig.ManifestFilename is not set.
+// When the Vite version is known, its manifest location is
+// tried first.
+var DefaultManifestFiles = []string{MANIFEST_FILE_V5, MANIFEST_FILE_V2}
+
+// readManifest finds and reads the manifest, returning its path
+// relative to AssetsPath along with its contents.
+func (vc *ViteConfig) readManifest(fsys fs.FS) (string, []byte, error) {
+	candidates := vc.manifestCandidates()
+
+	var err error
+
+	for _, candidate := range candidates {
+		var contents []byte
+
+		contents, err = fs.ReadFile(fsys, path.Join(vc.AssetsPath, candidate))
+		if err == nil {
+			return candidate, contents, nil
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", nil, err
+		}
+	}
+
+	return "", nil, fmt.Errorf("%w: %w", ErrNoManifest, err)
+}
+
+// applyEntryPoints makes the first of EntryPoints the default
+// EntryPoint, and adds every entry to Inputs under its file name
+// without extension. Inputs set explicitly take precedence.
+func (vc *ViteConfig) applyEntryPoints() {
+	if len(vc.EntryPoints) == 0 {
+		return
+	}
+
+	if vc.EntryPoint == "" {
+		vc.EntryPoint = vc.EntryPoints[0]
+	}
+
+	inputs := make(map[string]string, len(vc.Inputs)+len(vc.EntryPoints))
+	for name, src := range vc.Inputs {
+		inputs[name] = src
+	}
+
+	for _, entry := range vc.EntryPoints {
+		name := strings.TrimSuffix(path.Base(entry), path.Ext(entry))
+		if _, ok := inputs[name]; !ok {
+			inputs[name] = entry
+		}
+	}
+
+	vc.Inputs = inputs
+}
+
+// entryName returns the Inputs name of a source path.
+func entryName(inputs map[string]string, src string) string {
+	for name, inputSrc := range inputs {
+		if inputSrc == src {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// manifestCandidates lists where to look for the manifest:
+// ManifestFilename if set, else DefaultManifestFiles with the
+// location for the project's Vite version first.
+func (vc *ViteConfig) manifestCandidates() []string {
+	if vc.ManifestFilename != "" {
+		return []string{vc.ManifestFilename}
+	}
+
+	version := vc.ViteVersion
+	if vc.DevDefaults != nil && vc.DevDefaults.ViteMajorVer != "" {
+		version = vc.DevDefaults.ViteMajorVer
+	}
 
+	major, err := strconv.Atoi(version)
 	if err != nil {
+		return DefaultManifestFiles
+	}
+
+	preferred := MANIFEST_FILE_V2
+	if major >= 5 {
+		preferred = MANIFEST_FILE_V5
+	}
+
+	candidates := []string{preferred}
+	for _, candidate := range DefaultManifestFiles {
+		if candidate != preferred {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	return candidates
+}
+
+// correctFS returns an fs.FS pointing at the JS project. When
+// root is set it is used as the project's path inside fsys ("."
+// meaning fsys already points at it). Otherwise we look for
+// projectPath inside fsys and take a sub FS if it is there.
+//
+// This covers embed.FS, which does not set the top level dir as
+// the "current" dir for the FS, along with os.DirFS or any other
+// fs.FS, without needing to know its concrete type.
+//
+// @see https://github.com/golang/go/issues/43431
+func correctFS(fsys fs.FS, root, projectPath string) (fs.FS, error) {
+	root, projectPath = fsPath(root), fsPath(projectPath)
+
+	if root != "" {
+		if root == "." {
+			return fsys, nil
+		}
+
+		return fs.Sub(fsys, root)
+	}
+
+	if projectPath == "" || projectPath == "." {
+		return fsys, nil
+	}
+
+	// Make sure someone has not already taken a sub. Stat only
+	// needs Open, so this works for FS types without ReadDir.
+	info, err := fs.Stat(fsys, projectPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return nil, err
 	}
 
-	return vgo, nil
+	if err == nil && info.IsDir() {
+		// uncorrected FS, so take its subdir
+		return fs.Sub(fsys, projectPath)
+	}
+
+	return fsys, nil
+}
+
+// fsPath turns a configured path such as ./web/frontend/ or
+// build\dist into the slash-separated, unrooted form fs.FS
+// expects, at any depth. Empty stays empty.
+func fsPath(p string) string {
+	if p == "" {
+		return ""
+	}
+
+	return path.Clean(strings.Trim(strings.ReplaceAll(p, "\\", "/"), "/"))
+}
+
+// NewConfigFromFS returns a development ViteConfig for the
+// project at projectPath in fsys, with the defaults detected from
+// its package.json already applied. Any fs.FS works, so a
+// fstest.MapFS holding a synthetic package.json is enough to
+// exercise framework detection in tests.
+func NewConfigFromFS(fsys fs.FS, projectPath string) (*ViteConfig, error) {
+	config := &ViteConfig{
+		FS:            fsys,
+		Environment:   "development",
+		JSProjectPath: projectPath,
+	}
+
+	if projectPath == "" {
+		// "." rather than letting the defaults pick frontend
+		config.JSProjectPath = "."
+	}
+
+	if err := config.SetDevelopmentDefaults(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
 }
 
-// If we have an embedded FS, modify it to point to the
-// requested assets directory
-func correctEmbedFS(embedded fs.FS, assetsPath string) (fs.FS, error) {
-	// embed behaves a little strange: it does
-	// not set the top level dir as the "current"
-	// dir for the FS. This is almost never what you
-	// want, so we correct for this
-	//
-	// @see https://github.com/golang/go/issues/43431
-	//
-	if _, ok := embedded.(embed.FS); ok {
-		// Make sure someone has not already taken a sub:
-		_, err := fs.ReadDir(embedded, assetsPath)
-		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+// loadManifest returns a VitGo holding what NewVitGo derives
+// from the manifest's contents: the main entry's assets, the
+// parsed manifest and the indexes built from it.
+func (vc *ViteConfig) loadManifest(contents []byte) (*VitGo, error) {
+	manifest, err := parseManifest(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	// the configured entry first, else the first in the build
+	names := manifestKeys(contents)
+	if vc.EntryPoint != "" {
+		names = append([]string{vc.EntryPoint}, names...)
+	}
+
+	vgo, err := manifest.mainEntry(names)
+	if errors.Is(err, ErrNoEntryPoint) && (vc.SSRNoClient || vc.Platform == "astro") {
+		// server-only builds and astro's pages have no
+		// client entry
+		vgo, err = &VitGo{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	vgo.manifest = manifest
+	vgo.caseIndex = buildCaseIndex(manifestFiles(manifest))
+	vgo.integrity = manifestIntegrity(manifest)
+
+	if len(vc.Inputs) > 0 {
+		vgo.inputs, err = manifest.inputAssets(vc.Inputs)
+		if err != nil {
 			return nil, err
 		}
+	}
 
-		if err == nil {
-			// uncorrected FS, so take its subdir
-			embedded, err = fs.Sub(embedded, assetsPath)
-			if err != nil {
-				return nil, err
-			}
-		}
+	if primary, ok := vgo.inputs[entryName(vc.Inputs, vc.EntryPoint)]; ok && len(vc.EntryPoints) > 0 {
+		vgo.MainModule = primary.MainModule
+		vgo.Imports = primary.Imports
+		vgo.CSSModule = primary.CSSModule
 	}
 
-	return embedded, nil
+	return vgo, nil
 }
 
 // NewVitGo finds the manifest in the supplied file system
@@ -154,45 +728,191 @@ func NewVitGo(config *ViteConfig) (*VitGo, error) {
 	var vgo *VitGo
 	vgo = &VitGo{}
 
-	correctedFS, err := correctEmbedFS(config.FS, config.JSProjectPath)
+	loadStrategy, err := validateLoadStrategy(config.LoadStrategy)
 	if err != nil {
 		return nil, err
 	}
 
-	if config.Environment == "production" {
-		err := config.SetProductionDefaults()
-		if err != nil {
-			return nil, err
+	rootBehavior, err := validateRootBehavior(config.RootBehavior)
+	if err != nil {
+		return nil, err
+	}
+
+	config.applyEntryPoints()
+
+	explicitPlatform := config.Platform != ""
+	explicitEntry := config.EntryPoint != ""
+
+	if config.ResolveEnvironment() == "production" {
+		err = config.SetProductionDefaults()
+	} else {
+		err = config.SetDevelopmentDefaults()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	config.AssetsPath = fsPath(config.AssetsPath)
+
+	correctedFS, err := correctFS(config.FS, config.FSRoot, config.JSProjectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifestFile string
+
+	// The build is normally inside the project. An AssetsPath
+	// above it, such as a build.outDir of ../server/static, is
+	// read from FS itself instead.
+	distFS := correctedFS
+
+	if config.Environment == "production" && leavesProject(config.AssetsPath) {
+		resolved, ok := config.resolveInFS(config.AssetsPath)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrAssetsOutsideFS, config.AssetsPath)
 		}
 
+		distFS, config.AssetsPath = config.FS, resolved
+	}
+
+	if config.Environment == "production" {
 		// Get the manifest file
-		manifestFile := config.AssetsPath + "/manifest.json"
-		contents, err := fs.ReadFile(correctedFS, manifestFile)
+		var contents []byte
+		manifestFile, contents, err = config.readManifest(distFS)
+		if errors.Is(err, fs.ErrNotExist) && config.Platform == "astro" {
+			// astro builds are static pages, usually
+			// without a manifest
+			contents, err = []byte("{}"), nil
+		}
 
 		if err != nil {
 			return nil, err
 		}
 
-		vgo, err = ParseManifest(contents)
+		vgo, err = config.loadManifest(contents)
 		if err != nil {
 			return nil, err
 		}
 
+		vgo.manifestWatch = &manifestWatch{}
+
 	} else {
-		err := config.SetDevelopmentDefaults()
+		vgo.BaseURL = config.buildDevServerBaseURL()
+		vgo.HMRURL = config.buildHMRURL()
+		vgo.HMRWebSocketURL = config.buildHMRWebSocketURL()
+		vgo.MainModule = config.EntryPoint
+		vgo.devAddress = &devAddress{}
+		vgo.devProxy = newDevProxyTracker()
+
+		if config.WatchPackageJSON {
+			vgo.watch = newPackageWatch(config, explicitPlatform, explicitEntry)
+		}
+	}
+
+	if config.MetaSidecarPath != "" {
+		sidecar, err := loadSidecar(correctedFS, config.MetaSidecarPath)
 		if err != nil {
 			return nil, err
 		}
 
-		vgo.BaseURL = config.buildDevServerBaseURL()
-		vgo.MainModule = config.EntryPoint
+		vgo.Sidecar = sidecar
 	}
 
 	vgo.Environment = config.Environment
 	vgo.JSProjectPath = config.JSProjectPath
 	vgo.AssetPath = config.AssetsPath
+	vgo.URLPrefix = config.URLPrefix
+	vgo.BasePath = config.BasePath
+	vgo.DevClientURL = config.DevClientURL
+	vgo.manifestFile = manifestFile
+	vgo.manifestCache = &manifestCache{}
+	vgo.sriCache = &sriCache{}
+	vgo.siblingCache = &siblingCache{}
+	vgo.etagCache = &etagCache{}
 	vgo.Platform = config.Platform
-	vgo.DistFS = correctedFS
+	vgo.Frameworks = config.Frameworks
+	vgo.LoadStrategy = loadStrategy
+	vgo.RootBehavior = rootBehavior
+	vgo.TurboTrack = config.TurboTrack
+	vgo.SSRNoClient = config.SSRNoClient
+	vgo.Inputs = config.Inputs
+	vgo.PagesRoot = config.PagesRoot
+	vgo.DistFS = distFS
+	vgo.ServeSourceMaps = config.Environment != "production"
+
+	vgo.DisableLogging = config.DisableLogging
+
+	resolved := *config
+	vgo.config = &resolved
+	vgo.Logger = config.Logger
+
+	if config.Environment != "production" {
+		for _, warning := range config.secureContextWarnings() {
+			vgo.logger().Warn("vitgo: " + warning)
+		}
+	}
+
+	if config.LogStartupConfig {
+		logStartupConfig(vgo.logger(), config)
+	}
 
 	return vgo, nil
 }
+
+// logStartupConfig logs the resolved configuration. Only config
+// values are logged; nothing is read from the environment.
+func logStartupConfig(logger *slog.Logger, config *ViteConfig) {
+	mode := "dev"
+	if config.Environment == "production" {
+		mode = "prod"
+	}
+
+	logger.Info(
+		"vitgo: startup config",
+		"environment", config.Environment,
+		"mode", mode,
+		"entry", config.EntryPoint,
+		"vite_version", config.ViteVersion,
+		"assets_path", config.AssetsPath,
+		"platform", config.Platform,
+	)
+}
+
+// secureContextWarnings lists the configured dev features that
+// will not work as set up, one warning each. Browsers treat
+// localhost as a secure context, so plain HTTP only breaks
+// secure-context features on other hosts; an insecure HMR
+// websocket breaks on an HTTPS page anywhere.
+func (vc *ViteConfig) secureContextWarnings() []string {
+	var warnings []string
+
+	devServer := vc.buildDevServerBaseURL()
+	secureContext := vc.HTTPS || isLoopbackHost(vc.DevServerDomain)
+
+	if !secureContext && vc.DevDefaults != nil && vc.DevDefaults.HasPWAPlugin {
+		warnings = append(warnings, fmt.Sprintf(
+			"service worker dev serving requires HTTPS: %s is plain HTTP on a non-local host, "+
+				"so the PWA plugin's service worker will not register", devServer))
+	}
+
+	if vc.HTTPS && vc.HMRSecure != nil && !*vc.HMRSecure {
+		warnings = append(warnings, fmt.Sprintf(
+			"HMR over ws:// is blocked on HTTPS pages: HMRSecure is false but %s is HTTPS, "+
+				"so browsers refuse the HMR websocket as mixed content", devServer))
+	}
+
+	return warnings
+}
+
+// isLoopbackHost reports whether host is localhost or a
+// loopback address.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+
+	return ip != nil && ip.IsLoopback()
+}
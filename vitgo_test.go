@@ -0,0 +1,100 @@
+package vitgo
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// openOnlyFS hides every method of the wrapped FS but Open, as
+// for FS types without ReadDir or Stat.
+type openOnlyFS struct {
+	fsys fs.FS
+}
+
+func (o openOnlyFS) Open(name string) (fs.File, error) {
+	return o.fsys.Open(name)
+}
+
+func TestCorrectFS(t *testing.T) {
+	nested := fstest.MapFS{
+		"web/frontend/package.json": {Data: []byte("nested")},
+		"frontend/package.json":     {Data: []byte("frontend")},
+		"package.json":              {Data: []byte("top")},
+	}
+
+	tests := []struct {
+		name        string
+		fsys        fs.FS
+		root        string
+		projectPath string
+		want        string
+	}{
+		{"empty root finds project", nested, "", "frontend", "frontend"},
+		{"empty root without project", fstest.MapFS{"package.json": {Data: []byte("top")}}, "", "frontend", "top"},
+		{"empty root and project", nested, "", "", "top"},
+		{"dot root", nested, ".", "frontend", "top"},
+		{"nested root", nested, "web/frontend", "frontend", "nested"},
+		{"nested root with slashes", nested, "./web/frontend/", "", "nested"},
+		{"nested root with backslashes", nested, "web\\frontend", "", "nested"},
+		{"nested project", nested, "", "web/frontend", "nested"},
+		{"open-only FS finds project", openOnlyFS{nested}, "", "frontend", "frontend"},
+		{"open-only FS nested root", openOnlyFS{nested}, "web/frontend", "", "nested"},
+		{"open-only FS without project", openOnlyFS{fstest.MapFS{"package.json": {Data: []byte("top")}}}, "", "frontend", "top"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := correctFS(tt.fsys, tt.root, tt.projectPath)
+			if err != nil {
+				t.Fatalf("correctFS: %v", err)
+			}
+
+			buf, err := fs.ReadFile(got, "package.json")
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+
+			if string(buf) != tt.want {
+				t.Errorf("package.json = %q, want %q", buf, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecureContextWarnings(t *testing.T) {
+	secure, insecure := true, false
+	pwa := &JSAppParams{HasPWAPlugin: true}
+
+	tests := []struct {
+		name   string
+		config ViteConfig
+		want   []string
+	}{
+		{"nothing configured", ViteConfig{DevServerDomain: "dev.example.com"}, nil},
+		{"pwa on localhost", ViteConfig{DevServerDomain: "localhost", DevDefaults: pwa}, nil},
+		{"pwa over https", ViteConfig{DevServerDomain: "dev.example.com", HTTPS: true, DevDefaults: pwa}, nil},
+		{"pwa over http", ViteConfig{DevServerDomain: "dev.example.com", DevDefaults: pwa}, []string{"service worker"}},
+		{"hmr ws on https", ViteConfig{DevServerDomain: "localhost", HTTPS: true, HMRSecure: &insecure}, []string{"HMR over ws://"}},
+		{"hmr wss on https", ViteConfig{DevServerDomain: "localhost", HTTPS: true, HMRSecure: &secure}, nil},
+		{"both", ViteConfig{DevServerDomain: "10.0.0.5", DevDefaults: pwa, HMRSecure: &insecure}, []string{"service worker"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.DevServerPort = "5173"
+
+			got := tt.config.secureContextWarnings()
+			if len(got) != len(tt.want) {
+				t.Fatalf("warnings = %q, want %d", got, len(tt.want))
+			}
+
+			for i, want := range tt.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("warning %d = %q, want it to mention %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
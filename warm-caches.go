@@ -0,0 +1,142 @@
+package vitgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+// WARM_CONCURRENCY is how many files WarmCaches works on at once.
+const WARM_CONCURRENCY = 4
+
+// WarmCaches works out, in production, what would otherwise be
+// computed on first use: the ETags FileServer sends, the checks
+// of precompressed siblings, and, with EnableSRI, the integrity
+// hashes of the tag renderers. It covers index.html and every
+// file the manifest references; files larger than
+// MaxInlineOpBytes, when set, are skipped. The caches are shared
+// with serving, so run it in its own goroutine after a deploy.
+// It stops early when ctx is done, returning ctx.Err().
+// Failures for single files are returned together, and a summary
+// is logged.
+func (vg *VitGo) WarmCaches(ctx context.Context) error {
+	if vg.Environment != "production" || vg.DistFS == nil {
+		return nil
+	}
+
+	vg = vg.reloaded()
+
+	distDir, err := fs.Sub(vg.DistFS, vg.AssetPath)
+	if err != nil {
+		return err
+	}
+
+	files := []string{"index.html"}
+	if vg.manifest != nil {
+		files = append(files, manifestFiles(vg.manifest)...)
+	}
+
+	jobs := make(chan string)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errs    []error
+		warmed  int
+		skipped int
+	)
+
+	for i := 0; i < WARM_CONCURRENCY; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for file := range jobs {
+				ok, err := vg.warmFile(distDir, file)
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					errs = append(errs, err)
+				case ok:
+					warmed++
+				default:
+					skipped++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	seen := map[string]bool{}
+
+queue:
+	for _, file := range files {
+		if file == "" || isAbsoluteURL(file) || seen[file] {
+			continue
+		}
+
+		seen[file] = true
+
+		select {
+		case jobs <- file:
+		case <-ctx.Done():
+			break queue
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	vg.logger().Info("vitgo: warmed caches", "files", warmed, "skipped", skipped, "errors", len(errs))
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return errors.Join(errs...)
+}
+
+// warmFile fills the caches for one file, reporting whether it
+// did; a missing index.html or an oversized file is skipped.
+func (vg *VitGo) warmFile(distDir fs.FS, file string) (bool, error) {
+	name := strings.TrimPrefix(file, "/")
+
+	info, err := fs.Stat(distDir, name)
+	if errors.Is(err, fs.ErrNotExist) && name == "index.html" {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("could not warm %s: %w", name, err)
+	}
+
+	if info.IsDir() || (vg.MaxInlineOpBytes > 0 && info.Size() > vg.MaxInlineOpBytes) {
+		return false, nil
+	}
+
+	vg.fileETag(distDir, name)
+
+	for _, variant := range precompressedVariants {
+		sibling := vg.siblingPath(name, variant)
+
+		if _, err := fs.Stat(distDir, sibling); err == nil {
+			// a broken sibling is logged, and skipped when serving
+			if content, _, err := vg.openSibling(distDir, sibling, variant.encoding, info.Size()); err == nil {
+				content.Close()
+			}
+		}
+	}
+
+	if vg.EnableSRI && vg.expectedIntegrity(file) == "" {
+		if _, err := vg.computeIntegrity(file); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
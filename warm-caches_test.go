@@ -0,0 +1,80 @@
+package vitgo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestWarmCaches(t *testing.T) {
+	script := []byte(strings.Repeat("console.log('main');\n", 100))
+
+	fsys := prodFS()
+	fsys["dist/assets/main-4f2a1b.js"] = &fstest.MapFile{Data: script}
+	fsys["dist/assets/main-4f2a1b.js.gz"] = &fstest.MapFile{Data: gzipped(t, script)}
+	fsys["dist/assets/main-9c8d7e.css.gz"] = &fstest.MapFile{Data: []byte("broken")}
+
+	vg := newProdVitGo(t, fsys)
+	vg.EnableSRI = true
+	handler := fileServer(t, vg)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	// serving goes on while the caches warm
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 20; i++ {
+			getGzip(handler, "/assets/main-4f2a1b.js")
+			serve(handler, http.MethodGet, "/")
+		}
+	}()
+
+	if err := vg.WarmCaches(context.Background()); err != nil {
+		t.Fatalf("WarmCaches: %v", err)
+	}
+
+	wg.Wait()
+
+	if _, ok := vg.etagCache.etags["index.html"]; !ok {
+		t.Error("index.html ETag not cached")
+	}
+
+	if check, ok := vg.siblingCache.checked["assets/main-4f2a1b.js.gz"]; !ok || !check.valid {
+		t.Errorf("main-4f2a1b.js.gz check = %+v, %v, want valid", check, ok)
+	}
+
+	if check, ok := vg.siblingCache.checked["assets/main-9c8d7e.css.gz"]; !ok || check.valid {
+		t.Errorf("main-9c8d7e.css.gz check = %+v, %v, want invalid", check, ok)
+	}
+
+	for _, name := range []string{"dist/assets/main-4f2a1b.js", "dist/assets/main-9c8d7e.css"} {
+		if _, ok := vg.sriCache.hashes[name]; !ok {
+			t.Errorf("%s integrity not cached", name)
+		}
+	}
+}
+
+func TestWarmCachesMissingFile(t *testing.T) {
+	fsys := prodFS()
+	delete(fsys, "dist/assets/main-9c8d7e.css")
+
+	err := newProdVitGo(t, fsys).WarmCaches(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "main-9c8d7e.css") {
+		t.Errorf("WarmCaches = %v, want an error naming main-9c8d7e.css", err)
+	}
+}
+
+func TestWarmCachesCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := newProdVitGo(t, prodFS()).WarmCaches(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("WarmCaches = %v, want context.Canceled", err)
+	}
+}